@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"io"
+	"os"
+
+	"github.com/gofunct/goreflect"
+)
+
+func runConvert(args []string) error {
+	fs := flag.NewFlagSet("convert", flag.ExitOnError)
+	from := fs.String("f", "", "input format; detected from the input when empty")
+	to := fs.String("t", "json", "output format")
+	in := fs.String("i", "", "input file; stdin when empty")
+	out := fs.String("o", "", "output file; stdout when empty")
+	var opts goreflect.ConvertOptions
+	fs.BoolVar(&opts.PreserveCase, "preserve-case", false, "keep the case of keys instead of lower-casing them")
+	fs.BoolVar(&opts.PreserveOrder, "preserve-order", false, "keep the order of keys instead of sorting them")
+	fs.StringVar(&opts.Indent, "indent", "", "indentation unit for JSON output")
+	fs.Usage = func() {
+		fs.Output().Write([]byte("usage: goreflect convert [-f format] [-t format] [-i file] [-o file] < in > out\n"))
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	var r io.Reader = os.Stdin
+	if *in != "" {
+		f, err := os.Open(*in)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var src goreflect.TYPE
+	if *from == "" {
+		d, replay, err := goreflect.DetectType(r)
+		if err != nil {
+			return err
+		}
+		src, r = d.Type, replay
+	} else {
+		t, err := goreflect.ParseType(*from)
+		if err != nil {
+			return err
+		}
+		src = t
+	}
+	dst, err := goreflect.ParseType(*to)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = bufio.NewWriter(f)
+	}
+	if err := goreflect.Convert(r, src, w, dst, opts); err != nil {
+		return err
+	}
+	return w.Flush()
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/gofunct/goreflect"
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hclparse"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func runHCLDec(args []string) error {
+	fs := flag.NewFlagSet("hcldec", flag.ExitOnError)
+	spec := fs.String("spec", "", "the hcldec spec file to decode with (required)")
+	var vars listFlag
+	fs.Var(&vars, "vars", "variables as an inline JSON object or an HCL or JSON file; may be repeated")
+	diagsFormat := fs.String("diags", "text", "diagnostics format: text or json")
+	out := fs.String("out", "", "write the JSON result to this file instead of stdout")
+	schema := fs.Bool("schema", false, "print the JSON Schema of the spec's output instead of decoding")
+	fs.Usage = func() {
+		fs.Output().Write([]byte("usage: goreflect hcldec --spec file [--vars json|file]... [--diags text|json] [--out file] [input...]\n" +
+			"       goreflect hcldec --spec file --schema [--out file]\n\n" +
+			"Inputs are merged; with none, HCL is read from standard input.\n\n"))
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *spec == "" {
+		return fmt.Errorf("--spec is required")
+	}
+	if *diagsFormat != "text" && *diagsFormat != "json" {
+		return fmt.Errorf("unknown diagnostics format %q", *diagsFormat)
+	}
+	if *schema {
+		return writeSpecSchema(*spec, *diagsFormat, *out)
+	}
+	inputs := fs.Args()
+	if len(inputs) == 0 {
+		inputs = []string{"-"}
+	}
+
+	var diags hcl.Diagnostics
+	vals := make(map[string]cty.Value)
+	for i, arg := range vars {
+		v, d := goreflect.ParseVars(arg, i)
+		diags = append(diags, d...)
+		for k, val := range v {
+			vals[k] = val
+		}
+	}
+	var val cty.Value
+	if !diags.HasErrors() {
+		var d hcl.Diagnostics
+		val, d = goreflect.DecodeWithSpec(*spec, inputs, vals)
+		diags = append(diags, d...)
+	}
+
+	if len(diags) > 0 {
+		if err := writeDiags(*diagsFormat, diags, append(append([]string{*spec}, vars...), inputs...)); err != nil {
+			return err
+		}
+	}
+	if diags.HasErrors() {
+		return fmt.Errorf("%d error(s)", len(diags.Errs()))
+	}
+
+	b, err := goreflect.CtyToJSON(val)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, b, "", "  "); err != nil {
+		return err
+	}
+	return writeOutput(*out, buf.Bytes())
+}
+
+func writeSpecSchema(specFile, diagsFormat, out string) error {
+	spec, diags := goreflect.LoadSpecFile(specFile)
+	if len(diags) > 0 {
+		if err := writeDiags(diagsFormat, diags, []string{specFile}); err != nil {
+			return err
+		}
+	}
+	if diags.HasErrors() {
+		return fmt.Errorf("%d error(s)", len(diags.Errs()))
+	}
+	b, err := goreflect.SpecJSONSchema(spec.RootSpec)
+	if err != nil {
+		return err
+	}
+	return writeOutput(out, b)
+}
+
+// writeOutput writes b and a newline to the file out, or stdout.
+func writeOutput(out string, b []byte) error {
+	b = append(b, '\n')
+	if out != "" {
+		return ioutil.WriteFile(out, b, 0644)
+	}
+	_, err := os.Stdout.Write(b)
+	return err
+}
+
+// writeDiags writes diags to stderr. Text diagnostics quote the source of
+// the named files, which are parsed again for the purpose; stdin and
+// inline vars are skipped.
+func writeDiags(format string, diags hcl.Diagnostics, names []string) error {
+	if format == "json" {
+		wr := goreflect.NewJsonDiagWriter(os.Stderr)
+		wr.WriteDiagnostics(diags)
+		return wr.Flush()
+	}
+	p := hclparse.NewParser()
+	for _, name := range names {
+		switch {
+		case name == "-" || strings.HasPrefix(strings.TrimSpace(name), "{"):
+		case strings.HasSuffix(name, ".json"):
+			p.ParseJSONFile(name)
+		default:
+			p.ParseHCLFile(name)
+		}
+	}
+	return hcl.NewDiagnosticTextWriter(os.Stderr, p.Files(), 78, false).WriteDiagnostics(diags)
+}
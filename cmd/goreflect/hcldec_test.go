@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRunHCLDec(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hcldec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	write := func(name, src string) string {
+		name = filepath.Join(dir, name)
+		if err := ioutil.WriteFile(name, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return name
+	}
+	spec := write("spec.hcldec", `
+object {
+  attr "name" {
+    type     = string
+    required = true
+  }
+  attr "port" {
+    type = number
+  }
+}
+`)
+	input := write("main.hcl", "name = name\nport = port\n")
+	varsFile := write("vars.hcl", "name = \"from-file\"\nport = 80\n")
+	out := filepath.Join(dir, "out.json")
+
+	tests := []struct {
+		name string
+		args []string
+		want map[string]interface{}
+		err  bool
+	}{
+		{
+			name: "inline vars",
+			args: []string{"--vars", `{"name": "web", "port": 8080}`, input},
+			want: map[string]interface{}{"name": "web", "port": float64(8080)},
+		},
+		{
+			name: "vars file",
+			args: []string{"--vars", varsFile, input},
+			want: map[string]interface{}{"name": "from-file", "port": float64(80)},
+		},
+		{
+			name: "later vars win",
+			args: []string{"--vars", varsFile, "--vars", `{"port": 443}`, input},
+			want: map[string]interface{}{"name": "from-file", "port": float64(443)},
+		},
+		{name: "missing spec", args: []string{input}, err: true},
+		{name: "unknown diagnostics format", args: []string{"--diags", "xml", input}, err: true},
+		{name: "undefined variable", args: []string{"--diags", "json", input}, err: true},
+		{name: "bad inline vars", args: []string{"--diags", "json", "--vars", `{"name": }`, input}, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Remove(out)
+			args := tt.args
+			if tt.name != "missing spec" {
+				args = append([]string{"--spec", spec, "--out", out}, args...)
+			}
+			err := runHCLDec(args)
+			if tt.err {
+				if err == nil {
+					t.Error("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := ioutil.ReadFile(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got map[string]interface{}
+			if err := json.Unmarshal(b, &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gofunct/goreflect"
+)
+
+func runLintTags(args []string) error {
+	fs := flag.NewFlagSet("lint-tags", flag.ExitOnError)
+	format := fs.String("format", "text", "output format: text or json")
+	fs.Usage = func() {
+		fs.Output().Write([]byte("usage: goreflect lint-tags [-format text|json] [dir | dir/...]...\n"))
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *format != "text" && *format != "json" {
+		return fmt.Errorf("unknown output format %q", *format)
+	}
+
+	patterns := fs.Args()
+	if len(patterns) == 0 {
+		patterns = []string{"."}
+	}
+	var dirs []string
+	for _, p := range patterns {
+		expanded, err := expandPackages(p)
+		if err != nil {
+			return err
+		}
+		dirs = append(dirs, expanded...)
+	}
+
+	diags := []goreflect.TagDiagnostic{}
+	for _, dir := range dirs {
+		d, err := goreflect.LintTags(dir)
+		if err != nil {
+			return err
+		}
+		diags = append(diags, d...)
+	}
+
+	if *format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(diags); err != nil {
+			return err
+		}
+	} else {
+		for _, d := range diags {
+			fmt.Println(d)
+		}
+	}
+	if len(diags) > 0 {
+		return fmt.Errorf("%d problem(s)", len(diags))
+	}
+	return nil
+}
+
+// expandPackages resolves a dir/... pattern to every directory below dir
+// holding Go files, skipping vendor, testdata and hidden directories.
+func expandPackages(pattern string) ([]string, error) {
+	if !strings.HasSuffix(pattern, "/...") && pattern != "..." {
+		return []string{pattern}, nil
+	}
+	root := strings.TrimSuffix(strings.TrimSuffix(pattern, "..."), "/")
+	if root == "" {
+		root = "."
+	}
+	var dirs []string
+	err := filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !fi.IsDir() {
+			return nil
+		}
+		name := fi.Name()
+		if path != root && (name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+			return filepath.SkipDir
+		}
+		if matches, _ := filepath.Glob(filepath.Join(path, "*.go")); len(matches) > 0 {
+			dirs = append(dirs, path)
+		}
+		return nil
+	})
+	return dirs, err
+}
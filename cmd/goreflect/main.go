@@ -0,0 +1,51 @@
+// Command goreflect exposes goreflect's format tooling on the command line.
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+type command struct {
+	summary string
+	run     func(args []string) error
+}
+
+var commands = map[string]command{
+	"convert":      {"convert a document between formats", runConvert},
+	"hcldec":       {"decode HCL files with an hcldec spec", runHCLDec},
+	"lint-tags":    {"check struct tags for mistakes", runLintTags},
+	"rewrite-tags": {"add, remove or rename struct tags", runRewriteTags},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: goreflect <command> [flags]\n\ncommands:\n")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", name, commands[name].summary)
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		if os.Args[1] != "help" && os.Args[1] != "-h" && os.Args[1] != "--help" {
+			fmt.Fprintf(os.Stderr, "goreflect: unknown command %q\n\n", os.Args[1])
+		}
+		usage()
+		os.Exit(2)
+	}
+	if err := cmd.run(os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "goreflect %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
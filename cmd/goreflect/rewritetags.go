@@ -0,0 +1,130 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/gofunct/goreflect"
+)
+
+var namings = map[string]func(string) string{
+	"snake":      goreflect.SnakeCase,
+	"kebab":      goreflect.KebabCase,
+	"camel":      goreflect.CamelCase,
+	"lowercamel": goreflect.LowerCamelCase,
+	"lower":      strings.ToLower,
+	"field":      func(s string) string { return s },
+}
+
+// listFlag collects the values of a repeated flag.
+type listFlag []string
+
+func (l *listFlag) String() string     { return strings.Join(*l, ",") }
+func (l *listFlag) Set(v string) error { *l = append(*l, v); return nil }
+
+func runRewriteTags(args []string) error {
+	fs := flag.NewFlagSet("rewrite-tags", flag.ExitOnError)
+	var remove, copies, add, transform listFlag
+	fs.Var(&remove, "remove", "remove a tag key, e.g. xml")
+	fs.Var(&copies, "copy", "copy names from one key to another, e.g. json:hcl")
+	fs.Var(&add, "add", "add a key named from the field, e.g. yaml:snake or yaml:snake,omitempty")
+	fs.Var(&transform, "transform", "rename existing names of a key, e.g. yaml:snake")
+	write := fs.Bool("w", false, "write changes to the files instead of stdout")
+	list := fs.Bool("l", false, "only list the files that would change")
+	fs.Usage = func() {
+		fs.Output().Write([]byte("usage: goreflect rewrite-tags [-w] [-l] [-remove key] [-copy from:to] [-add key:naming[,opts]] [-transform key:naming] [dir | dir/...]...\n\n" +
+			"Rules run in the order remove, copy, add, transform. Namings: snake, kebab, camel, lowercamel, lower, field.\n\n"))
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	var rules []goreflect.TagRule
+	if len(remove) > 0 {
+		rules = append(rules, goreflect.RemoveTag(remove...))
+	}
+	for _, c := range copies {
+		from, to, err := splitRule(c)
+		if err != nil {
+			return err
+		}
+		rules = append(rules, goreflect.CopyTag(from, to))
+	}
+	for _, a := range add {
+		key, spec, err := splitRule(a)
+		if err != nil {
+			return err
+		}
+		parts := strings.Split(spec, ",")
+		naming, ok := namings[parts[0]]
+		if !ok {
+			return fmt.Errorf("unknown naming %q", parts[0])
+		}
+		rules = append(rules, goreflect.AddTag(key, naming, parts[1:]...))
+	}
+	for _, t := range transform {
+		key, spec, err := splitRule(t)
+		if err != nil {
+			return err
+		}
+		naming, ok := namings[spec]
+		if !ok {
+			return fmt.Errorf("unknown naming %q", spec)
+		}
+		rules = append(rules, goreflect.TransformTag(key, naming))
+	}
+	if len(rules) == 0 {
+		fs.Usage()
+		return fmt.Errorf("no rules given")
+	}
+
+	patterns := fs.Args()
+	if len(patterns) == 0 {
+		patterns = []string{"."}
+	}
+	files := make(map[string][]byte)
+	for _, p := range patterns {
+		dirs, err := expandPackages(p)
+		if err != nil {
+			return err
+		}
+		for _, dir := range dirs {
+			changed, err := goreflect.RewriteTags(dir, rules...)
+			if err != nil {
+				return err
+			}
+			for path, b := range changed {
+				files[path] = b
+			}
+		}
+	}
+
+	paths := make([]string, 0, len(files))
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	switch {
+	case *list:
+		for _, p := range paths {
+			fmt.Println(p)
+		}
+	case *write:
+		return goreflect.WriteRewrittenTags(files)
+	default:
+		for _, p := range paths {
+			os.Stdout.Write(files[p])
+		}
+	}
+	return nil
+}
+
+func splitRule(s string) (string, string, error) {
+	i := strings.IndexByte(s, ':')
+	if i <= 0 || i == len(s)-1 {
+		return "", "", fmt.Errorf("expected key:value, got %q", s)
+	}
+	return s[:i], s[i+1:], nil
+}
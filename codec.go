@@ -0,0 +1,271 @@
+package goreflect
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/hcl/hcl/printer"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Codec converts between a serialized format and a generic map.
+type Codec interface {
+	// Decode reads a document from r and stores its top level keys in c.
+	Decode(r io.Reader, c map[string]interface{}) error
+	// Encode writes c to w in the codec's format.
+	Encode(w io.Writer, c map[string]interface{}) error
+	// Extensions lists the file extensions of the format, including the leading dot.
+	Extensions() []string
+	// MimeType is the media type of the format.
+	MimeType() string
+}
+
+// UnsupportedTypeError is returned when no Codec is registered for a TYPE.
+type UnsupportedTypeError struct {
+	Type TYPE
+}
+
+func (e *UnsupportedTypeError) Error() string {
+	return fmt.Sprintf("%s: no codec registered for type %d", Unsupported, int(e.Type))
+}
+
+type codecEntry struct {
+	name  string
+	codec Codec
+}
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = make(map[TYPE]codecEntry)
+)
+
+// RegisterCodec makes a codec available to MarshalReader and MarshalWriter
+// under the given type and name. Registering a type twice replaces the
+// previous codec.
+func RegisterCodec(t TYPE, name string, c Codec) {
+	if c == nil {
+		panic("goreflect: RegisterCodec codec is nil")
+	}
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	codecs[t] = codecEntry{name: strings.ToUpper(name), codec: c}
+}
+
+// LookupCodec returns the codec registered for t.
+func LookupCodec(t TYPE) (Codec, error) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	e, ok := codecs[t]
+	if !ok {
+		return nil, &UnsupportedTypeError{Type: t}
+	}
+	return e.codec, nil
+}
+
+// lookupCodecWith is LookupCodec returning a codec configured with the
+// options for data when they are set.
+func lookupCodecWith(data TYPE, proto *ProtoOptions, tf *TFOptions) (Codec, error) {
+	switch {
+	case data == PROTO && proto != nil:
+		return proto.codec()
+	case data == TF && tf != nil:
+		return &TFCodec{Options: *tf}, nil
+	}
+	return LookupCodec(data)
+}
+
+// TypeForExtension returns the type whose codec claims the extension of
+// filename, e.g. ".yml" or "config.yml".
+func TypeForExtension(filename string) (TYPE, bool) {
+	ext := strings.ToLower(filepath.Ext(filename))
+	if ext == "" {
+		ext = "." + strings.TrimPrefix(strings.ToLower(filename), ".")
+	}
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	for _, t := range registeredTypesLocked() {
+		for _, e := range codecs[t].codec.Extensions() {
+			if strings.ToLower(e) == ext {
+				return t, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// TypeForName returns the type registered under name, ignoring case.
+func TypeForName(name string) (TYPE, bool) {
+	name = strings.ToUpper(name)
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	for _, t := range registeredTypesLocked() {
+		if codecs[t].name == name {
+			return t, true
+		}
+	}
+	return 0, false
+}
+
+// RegisteredTypes lists every type with a codec, in ascending order.
+func RegisteredTypes() []TYPE {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	return registeredTypesLocked()
+}
+
+func registeredTypesLocked() []TYPE {
+	types := make([]TYPE, 0, len(codecs))
+	for t := range codecs {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types
+}
+
+func typeName(t TYPE) (string, bool) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	e, ok := codecs[t]
+	return e.name, ok
+}
+
+func init() {
+	RegisterCodec(JSON, "JSON", jsonCodec{})
+	RegisterCodec(YAML, "YAML", yamlCodec{})
+	RegisterCodec(XML, "XML", xmlCodec{})
+	RegisterCodec(HCL, "HCL", hclCodec{})
+	RegisterCodec(TOML, "TOML", tomlCodec{})
+	RegisterCodec(PROPERTIES, "PROPERTIES", propertiesCodec{})
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Decode(r io.Reader, c map[string]interface{}) error {
+	b, err := readAll(r)
+	if err != nil {
+		return err
+	}
+	return errors.WithStack(json.Unmarshal(b, &c))
+}
+
+func (jsonCodec) Encode(w io.Writer, c map[string]interface{}) error {
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	_, err = fmt.Fprintf(w, "%s", b)
+	return errors.WithStack(err)
+}
+
+func (jsonCodec) Extensions() []string { return []string{".json"} }
+func (jsonCodec) MimeType() string     { return "application/json" }
+
+type yamlCodec struct{}
+
+func (yamlCodec) Decode(r io.Reader, c map[string]interface{}) error {
+	b, err := readAll(r)
+	if err != nil {
+		return err
+	}
+	return errors.WithStack(yaml.Unmarshal(b, &c))
+}
+
+func (yamlCodec) Encode(w io.Writer, c map[string]interface{}) error {
+	b, err := yaml.Marshal(c)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	_, err = fmt.Fprintf(w, "%s", b)
+	return errors.WithStack(err)
+}
+
+func (yamlCodec) Extensions() []string { return []string{".yaml", ".yml"} }
+func (yamlCodec) MimeType() string     { return "application/x-yaml" }
+
+type xmlCodec struct{}
+
+func (xmlCodec) Decode(r io.Reader, c map[string]interface{}) error {
+	b, err := readAll(r)
+	if err != nil {
+		return err
+	}
+	return errors.WithStack(xml.Unmarshal(b, &c))
+}
+
+func (xmlCodec) Encode(w io.Writer, c map[string]interface{}) error {
+	return errors.WithStack(EncodeXML(c, w))
+}
+
+func (xmlCodec) Extensions() []string { return []string{".xml"} }
+func (xmlCodec) MimeType() string     { return "application/xml" }
+
+type hclCodec struct{}
+
+func (hclCodec) Decode(r io.Reader, c map[string]interface{}) error {
+	b, err := readAll(r)
+	if err != nil {
+		return err
+	}
+	obj, err := hcl.Parse(string(b))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(hcl.DecodeObject(&c, obj.Node))
+}
+
+func (hclCodec) Encode(w io.Writer, c map[string]interface{}) error {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	ast, err := hcl.Parse(string(b))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(printer.Fprint(w, ast.Node))
+}
+
+func (hclCodec) Extensions() []string { return []string{".hcl"} }
+func (hclCodec) MimeType() string     { return "application/x-hcl" }
+
+func readAll(r io.Reader) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if _, err := buf.ReadFrom(r); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return buf.Bytes(), nil
+}
+
+// stringKeys recursively converts map[interface{}]interface{} values, as
+// produced by the YAML decoder, into map[string]interface{}.
+func stringKeys(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			m[StrVal(k)] = stringKeys(val)
+		}
+		return m
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			m[k] = stringKeys(val)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, val := range v {
+			s[i] = stringKeys(val)
+		}
+		return s
+	}
+	return v
+}
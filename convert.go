@@ -0,0 +1,67 @@
+package goreflect
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// ConvertOptions tunes Convert.
+type ConvertOptions struct {
+	// PreserveCase keeps keys as written in the input instead of
+	// lower-casing them like MarshalReader does.
+	PreserveCase bool
+	// PreserveOrder writes keys in the order they appear in the input,
+	// for formats that support it, instead of sorting them.
+	PreserveOrder bool
+	// Indent is the indentation unit of JSON output. Empty means two spaces.
+	Indent string
+}
+
+// Convert decodes in as from and writes it to out as to. Unless
+// opts.PreserveOrder is set keys are written in sorted order, so converting
+// the same input always yields the same output.
+func Convert(in io.Reader, from TYPE, out io.Writer, to TYPE, opts ConvertOptions) error {
+	if _, err := LookupCodec(to); err != nil {
+		return err
+	}
+
+	var m *OrderedMap
+	if opts.PreserveOrder {
+		var err error
+		m, err = MarshalReaderOrdered(in, from, DecodeOptions{PreserveCase: opts.PreserveCase})
+		if err != nil {
+			return errors.Wrapf(err, "decoding %s", from)
+		}
+	} else {
+		c := make(map[string]interface{})
+		if err := MarshalReaderWith(in, from, c, DecodeOptions{PreserveCase: opts.PreserveCase}); err != nil {
+			return errors.Wrapf(err, "decoding %s", from)
+		}
+		m = OrderedMapFrom(c)
+	}
+
+	if to == JSON && opts.Indent != "" {
+		b, err := json.MarshalIndent(m, "", opts.Indent)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		_, err = fmt.Fprintf(out, "%s\n", b)
+		return errors.WithStack(err)
+	}
+	return errors.Wrapf(MarshalWriterOrdered(out, m, to), "encoding %s", to)
+}
+
+// ParseType resolves a format name such as "yaml" or an extension such as
+// "yml" or ".tfstate" to its registered type.
+func ParseType(name string) (TYPE, error) {
+	if t, ok := TypeForName(name); ok {
+		return t, nil
+	}
+	if t, ok := TypeForExtension("." + name); ok && name != "" {
+		return t, nil
+	}
+	return 0, errors.Errorf("unknown format %q", name)
+}
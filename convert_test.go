@@ -0,0 +1,107 @@
+package goreflect
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConvertPreserveOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		from    TYPE
+		ordered string
+	}{
+		{
+			name: "hcl blocks",
+			in: `zone = "b"
+service "web" {
+  port = 80
+  host = "a"
+}
+service "db" {
+  port = 5432
+}
+`,
+			from:    HCL,
+			ordered: `{"zone":"b","service":[{"web":[{"port":80,"host":"a"}]},{"db":[{"port":5432}]}]}`,
+		},
+		{
+			name:    "hcl nested objects",
+			in:      "b = { y = 1\n x = [1, 2] }\na = true\n",
+			from:    HCL,
+			ordered: `{"b":[{"y":1,"x":[1,2]}],"a":true}`,
+		},
+		{
+			name:    "yaml",
+			in:      "z: 1\na:\n  w: [1, 2]\n  b: x\n",
+			from:    YAML,
+			ordered: `{"z":1,"a":{"w":[1,2],"b":"x"}}`,
+		},
+		{
+			name:    "json",
+			in:      `{"z": 1, "a": {"y": [1, 2], "b": "x"}}`,
+			from:    JSON,
+			ordered: `{"z":1,"a":{"y":[1,2],"b":"x"}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sorted, ordered bytes.Buffer
+			if err := Convert(strings.NewReader(tt.in), tt.from, &sorted, JSON, ConvertOptions{}); err != nil {
+				t.Fatal(err)
+			}
+			if err := Convert(strings.NewReader(tt.in), tt.from, &ordered, JSON, ConvertOptions{PreserveOrder: true}); err != nil {
+				t.Fatal(err)
+			}
+
+			var a, b interface{}
+			if err := json.Unmarshal(sorted.Bytes(), &a); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal(ordered.Bytes(), &b); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(a, b) {
+				t.Errorf("PreserveOrder changed the data:\n%s\n%s", sorted.Bytes(), ordered.Bytes())
+			}
+
+			var compact bytes.Buffer
+			if err := json.Compact(&compact, ordered.Bytes()); err != nil {
+				t.Fatal(err)
+			}
+			if compact.String() != tt.ordered {
+				t.Errorf("got %s, want %s", compact.Bytes(), tt.ordered)
+			}
+		})
+	}
+}
+
+func TestConvertRoundTrip(t *testing.T) {
+	const in = "name: web\nports:\n  - 80\n  - 443\ntls:\n  enabled: true\n"
+	for _, to := range []TYPE{JSON, YAML, TOML} {
+		for _, preserve := range []bool{false, true} {
+			opts := ConvertOptions{PreserveOrder: preserve}
+			var mid, out bytes.Buffer
+			if err := Convert(strings.NewReader(in), YAML, &mid, to, opts); err != nil {
+				t.Fatalf("%s: %v", to, err)
+			}
+			if err := Convert(&mid, to, &out, YAML, opts); err != nil {
+				t.Fatalf("%s: %v", to, err)
+			}
+			a, b := make(map[string]interface{}), make(map[string]interface{})
+			if err := MarshalReader(strings.NewReader(in), YAML, a); err != nil {
+				t.Fatal(err)
+			}
+			if err := MarshalReader(&out, YAML, b); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(a, b) {
+				t.Errorf("%s (PreserveOrder %v): got %v, want %v", to, preserve, b, a)
+			}
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package goreflect
+
+import "reflect"
+
+// DeepCopy returns a copy of v that shares no memory with it through
+// exported fields. Pointers, maps and slices reached more than once, as in
+// cycles, are copied once, so the copy has the same shape as v. Unexported
+// struct fields, channels, functions and time.Time values are copied as
+// they are: the internals of types such as sync.Mutex or os.File are not
+// duplicated.
+func DeepCopy(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	c := &copier{seen: make(map[copyKey]reflect.Value)}
+	return c.copy(reflect.ValueOf(v)).Interface()
+}
+
+// copyKey identifies a pointer, map or slice already copied.
+type copyKey struct {
+	ptr uintptr
+	len int
+	t   reflect.Type
+}
+
+type copier struct {
+	seen map[copyKey]reflect.Value
+}
+
+func (c *copier) copy(v reflect.Value) reflect.Value {
+	out := reflect.New(v.Type()).Elem()
+	c.copyInto(out, v)
+	return out
+}
+
+// copyInto copies src into dst, which must be settable. src must not have
+// been read through an unexported field.
+func (c *copier) copyInto(dst, src reflect.Value) {
+	switch src.Kind() {
+	case reflect.Ptr:
+		if src.IsNil() {
+			return
+		}
+		k := copyKey{ptr: src.Pointer(), t: src.Type()}
+		if p, ok := c.seen[k]; ok {
+			dst.Set(p)
+			return
+		}
+		p := reflect.New(src.Type().Elem())
+		c.seen[k] = p
+		c.copyInto(p.Elem(), src.Elem())
+		dst.Set(p)
+	case reflect.Interface:
+		if !src.IsNil() {
+			dst.Set(c.copy(src.Elem()))
+		}
+	case reflect.Map:
+		if src.IsNil() {
+			return
+		}
+		k := copyKey{ptr: src.Pointer(), t: src.Type()}
+		if m, ok := c.seen[k]; ok {
+			dst.Set(m)
+			return
+		}
+		m := reflect.MakeMapWithSize(src.Type(), src.Len())
+		c.seen[k] = m
+		for _, key := range src.MapKeys() {
+			m.SetMapIndex(c.copy(key), c.copy(src.MapIndex(key)))
+		}
+		dst.Set(m)
+	case reflect.Slice:
+		if src.IsNil() {
+			return
+		}
+		k := copyKey{ptr: src.Pointer(), len: src.Len(), t: src.Type()}
+		if s, ok := c.seen[k]; ok {
+			dst.Set(s)
+			return
+		}
+		s := reflect.MakeSlice(src.Type(), src.Len(), src.Cap())
+		c.seen[k] = s
+		for i := 0; i < src.Len(); i++ {
+			c.copyInto(s.Index(i), src.Index(i))
+		}
+		dst.Set(s)
+	case reflect.Array:
+		for i := 0; i < src.Len(); i++ {
+			c.copyInto(dst.Index(i), src.Index(i))
+		}
+	case reflect.Struct:
+		// a shallow copy first, which the exported fields then replace
+		dst.Set(src)
+		if src.Type() == timeType {
+			return
+		}
+		t := src.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).PkgPath == "" {
+				c.copyInto(dst.Field(i), src.Field(i))
+			}
+		}
+	default:
+		dst.Set(src)
+	}
+}
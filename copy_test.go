@@ -0,0 +1,99 @@
+package goreflect
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+type copyNode struct {
+	Name string
+	Next *copyNode
+}
+
+type copyHidden struct {
+	Public  []int
+	private *int
+	mu      sync.Mutex
+}
+
+func TestDeepCopy(t *testing.T) {
+	shared := &copyNode{Name: "shared"}
+	n := 1
+	tests := []struct {
+		name string
+		in   interface{}
+	}{
+		{"nil", nil},
+		{"scalar", 42},
+		{"map of slices", map[string][]int{"a": {1, 2}, "b": nil}},
+		{"nested interfaces", map[string]interface{}{"a": []interface{}{1, "x", nil}, "b": map[string]interface{}{"c": nil}}},
+		{"nil interface field", struct{ V interface{} }{}},
+		{"array", [2][]string{{"a"}, {"b"}}},
+		{"shared pointers", []*copyNode{shared, shared}},
+		{"time", struct{ T time.Time }{time.Date(2020, 1, 2, 3, 4, 5, 0, time.FixedZone("X", 3600))}},
+		{"unexported fields", &copyHidden{Public: []int{1}, private: &n}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DeepCopy(tt.in)
+			if !reflect.DeepEqual(got, tt.in) {
+				t.Errorf("got %#v, want %#v", got, tt.in)
+			}
+		})
+	}
+}
+
+func TestDeepCopySharesNothing(t *testing.T) {
+	in := map[string][]int{"a": {1, 2}}
+	out := DeepCopy(in).(map[string][]int)
+	out["a"][0] = 9
+	out["b"] = nil
+	if in["a"][0] != 1 || len(in) != 1 {
+		t.Errorf("copy shares memory with the original: %v", in)
+	}
+
+	shared := &copyNode{Name: "shared"}
+	nodes := DeepCopy([]*copyNode{shared, shared}).([]*copyNode)
+	if nodes[0] != nodes[1] {
+		t.Error("a pointer reached twice was copied twice")
+	}
+	if nodes[0] == shared {
+		t.Error("pointer was not copied")
+	}
+}
+
+func TestDeepCopyCycle(t *testing.T) {
+	a := &copyNode{Name: "a"}
+	a.Next = &copyNode{Name: "b", Next: a}
+	c := DeepCopy(a).(*copyNode)
+	if c == a || c.Next == a.Next {
+		t.Fatal("nodes were not copied")
+	}
+	if c.Next.Next != c {
+		t.Error("cycle was not preserved")
+	}
+	if c.Name != "a" || c.Next.Name != "b" {
+		t.Errorf("got %s, %s", c.Name, c.Next.Name)
+	}
+
+	m := map[string]interface{}{}
+	m["self"] = m
+	cm := DeepCopy(m).(map[string]interface{})
+	if reflect.ValueOf(cm["self"]).Pointer() != reflect.ValueOf(cm).Pointer() {
+		t.Error("map cycle was not preserved")
+	}
+}
+
+func TestDeepCopyUnexported(t *testing.T) {
+	n := 1
+	in := &copyHidden{Public: []int{1}, private: &n}
+	out := DeepCopy(in).(*copyHidden)
+	if out.private != in.private {
+		t.Error("unexported pointer was deep copied")
+	}
+	if &out.Public[0] == &in.Public[0] {
+		t.Error("exported slice was not copied")
+	}
+}
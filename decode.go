@@ -0,0 +1,330 @@
+package goreflect
+
+import (
+	"bytes"
+	"encoding"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// FieldError is an error decoding one field. Path is made of Go field
+// names, slice indexes and map keys, e.g. "Servers[0].Port".
+type FieldError struct {
+	Path string
+	Err  error
+}
+
+func (e *FieldError) Error() string {
+	if e.Path == "" {
+		return e.Err.Error()
+	}
+	return e.Path + ": " + e.Err.Error()
+}
+
+// DecodeError collects every FieldError of a decode.
+type DecodeError struct {
+	Errors []*FieldError
+}
+
+func (e *DecodeError) Error() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%d error(s) decoding:", len(e.Errors))
+	for _, fe := range e.Errors {
+		buf.WriteString("\n* ")
+		buf.WriteString(fe.Error())
+	}
+	return buf.String()
+}
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	timeType            = reflect.TypeOf(time.Time{})
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// DecodeInto decodes r as t into out, which must be a pointer to a struct
+// or a map.
+//
+// Struct fields are named by the tag for the format, like `yaml:"name"` for
+// YAML or `hcl:"name"` for HCL, and by their Go name otherwise. Names are
+// matched case-insensitively. A name of "-" skips the field and embedded
+// structs, or fields tagged ",inline" or ",squash", are decoded from the
+// enclosing object. Values are converted weakly with the To*E functions,
+// so "8080" decodes into an int. Every failing field is reported in a
+// *DecodeError.
+func DecodeInto(r io.Reader, t TYPE, out interface{}) error {
+	m, err := decodeTree(r, t)
+	if err != nil {
+		return err
+	}
+	return decodeMap(m.ToMap(), strings.ToLower(t.String()), out)
+}
+
+func decodeMap(c map[string]interface{}, tag string, out interface{}) error {
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.Errorf("DecodeInto: expected a non-nil pointer, got %T", out)
+	}
+	d := &structDecoder{tag: tag}
+	d.decode("", c, rv.Elem())
+	if len(d.errs) > 0 {
+		return &DecodeError{Errors: d.errs}
+	}
+	return nil
+}
+
+type structDecoder struct {
+	tag  string
+	errs []*FieldError
+}
+
+func (d *structDecoder) fail(path string, err error) {
+	d.errs = append(d.errs, &FieldError{Path: path, Err: err})
+}
+
+func (d *structDecoder) decode(path string, in interface{}, v reflect.Value) {
+	if in == nil {
+		return
+	}
+
+	switch v.Type() {
+	case durationType:
+		dur, err := ToDurationE(in)
+		d.set(path, v, reflect.ValueOf(dur), err)
+		return
+	case timeType:
+		tim, err := ToTimeE(in)
+		d.set(path, v, reflect.ValueOf(tim), err)
+		return
+	}
+
+	if v.CanAddr() && v.Kind() != reflect.Ptr && v.Addr().Type().Implements(textUnmarshalerType) {
+		if s, ok := in.(string); ok {
+			if err := v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+				d.fail(path, err)
+			}
+			return
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		d.decode(path, in, v.Elem())
+	case reflect.Interface:
+		if v.NumMethod() == 0 {
+			v.Set(reflect.ValueOf(in))
+			return
+		}
+		d.fail(path, errors.Errorf("cannot decode into %s", v.Type()))
+	case reflect.String:
+		s, err := ToStringE(in)
+		d.set(path, v, reflect.ValueOf(s), err)
+	case reflect.Bool:
+		b, err := ToBoolE(in)
+		d.set(path, v, reflect.ValueOf(b), err)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := ToInt64E(in)
+		if err == nil && v.OverflowInt(i) {
+			err = errors.Errorf("%d overflows %s", i, v.Type())
+		}
+		if err == nil {
+			v.SetInt(i)
+		}
+		d.set(path, v, reflect.Value{}, err)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, err := ToUint64E(in)
+		if err == nil && v.OverflowUint(u) {
+			err = errors.Errorf("%d overflows %s", u, v.Type())
+		}
+		if err == nil {
+			v.SetUint(u)
+		}
+		d.set(path, v, reflect.Value{}, err)
+	case reflect.Float32, reflect.Float64:
+		f, err := ToFloat64E(in)
+		if err == nil {
+			v.SetFloat(f)
+		}
+		d.set(path, v, reflect.Value{}, err)
+	case reflect.Slice, reflect.Array:
+		d.decodeSlice(path, in, v)
+	case reflect.Map:
+		d.decodeMap(path, in, v)
+	case reflect.Struct:
+		m, err := objectOf(in)
+		if err != nil {
+			d.fail(path, err)
+			return
+		}
+		d.decodeStruct(path, m, v)
+	default:
+		d.fail(path, errors.Errorf("cannot decode into %s", v.Type()))
+	}
+}
+
+// set stores val in v unless err is set, in which case it is recorded. An
+// invalid val means the value was already stored.
+func (d *structDecoder) set(path string, v, val reflect.Value, err error) {
+	if err != nil {
+		d.fail(path, err)
+		return
+	}
+	if val.IsValid() {
+		v.Set(val.Convert(v.Type()))
+	}
+}
+
+func (d *structDecoder) decodeSlice(path string, in interface{}, v reflect.Value) {
+	if str, ok := in.(string); ok && v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+		v.SetBytes([]byte(str))
+		return
+	}
+	s, err := ToSliceE(stringKeys(in))
+	if err != nil {
+		if rv := reflect.ValueOf(in); rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+			s = make([]interface{}, rv.Len())
+			for i := range s {
+				s[i] = rv.Index(i).Interface()
+			}
+		} else {
+			// a single value decodes as a list of one
+			s = []interface{}{in}
+		}
+	}
+
+	if v.Kind() == reflect.Array {
+		if len(s) > v.Len() {
+			d.fail(path, errors.Errorf("%d values do not fit in %s", len(s), v.Type()))
+			return
+		}
+	} else {
+		v.Set(reflect.MakeSlice(v.Type(), len(s), len(s)))
+	}
+	for i, elem := range s {
+		d.decode(fmt.Sprintf("%s[%d]", path, i), elem, v.Index(i))
+	}
+}
+
+func (d *structDecoder) decodeMap(path string, in interface{}, v reflect.Value) {
+	m, err := objectOf(in)
+	if err != nil {
+		d.fail(path, err)
+		return
+	}
+	if v.IsNil() {
+		v.Set(reflect.MakeMapWithSize(v.Type(), len(m)))
+	}
+	for _, k := range sortedKeys(m) {
+		kv := reflect.New(v.Type().Key()).Elem()
+		n := len(d.errs)
+		d.decode(fmt.Sprintf("%s[%s]", path, k), k, kv)
+		ev := reflect.New(v.Type().Elem()).Elem()
+		d.decode(fmt.Sprintf("%s[%s]", path, k), m[k], ev)
+		if len(d.errs) == n {
+			v.SetMapIndex(kv, ev)
+		}
+	}
+}
+
+func (d *structDecoder) decodeStruct(path string, m map[string]interface{}, v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, opts, err := fieldName(t, i, d.tag)
+		if err != nil {
+			d.fail(joinPath(path, f.Name), err)
+			continue
+		}
+		if name == "-" {
+			continue
+		}
+		fv := v.Field(i)
+		if (f.Anonymous && name == "") || opts["inline"] || opts["squash"] {
+			if fv.Kind() == reflect.Ptr {
+				if fv.IsNil() && fv.CanSet() {
+					fv.Set(reflect.New(fv.Type().Elem()))
+				}
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct {
+				d.decodeStruct(path, m, fv)
+				continue
+			}
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		key, ok := foldKey(m, name)
+		if !ok {
+			continue
+		}
+		d.decode(joinPath(path, f.Name), m[key], fv)
+	}
+}
+
+// fieldName returns the name and options given to field i of t by the tag
+// for the format. Both are empty when the field has no such tag.
+func fieldName(t reflect.Type, i int, tag string) (string, map[string]bool, error) {
+	p := defaultTagCache.field(t, i)
+	if p.err != nil {
+		return "", nil, p.err
+	}
+	vals := p.vals[tag]
+	if len(vals) == 0 {
+		return "", nil, nil
+	}
+	parts := strings.Split(vals[len(vals)-1], ",")
+	opts := make(map[string]bool, len(parts)-1)
+	for _, o := range parts[1:] {
+		opts[strings.TrimSpace(o)] = true
+	}
+	return parts[0], opts, nil
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// objectOf returns in as a map. Lists of maps, as produced by HCL blocks,
+// are merged.
+func objectOf(in interface{}) (map[string]interface{}, error) {
+	in = stringKeys(in)
+	if s, ok := in.([]interface{}); ok {
+		merged := make(map[string]interface{})
+		for _, elem := range s {
+			m, ok := stringKeys(elem).(map[string]interface{})
+			if !ok {
+				return nil, errors.Errorf("expected an object, got %T", elem)
+			}
+			for k, v := range m {
+				merged[k] = v
+			}
+		}
+		return merged, nil
+	}
+	if m, ok := in.([]map[string]interface{}); ok {
+		merged := make(map[string]interface{})
+		for _, elem := range m {
+			for k, v := range elem {
+				merged[k] = v
+			}
+		}
+		return merged, nil
+	}
+	m, err := ToStringMapE(in)
+	return m, errors.WithStack(err)
+}
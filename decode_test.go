@@ -0,0 +1,123 @@
+package goreflect
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type decodeBase struct {
+	ID int `yaml:"id" hcl:"id"`
+}
+
+type decodeServer struct {
+	Host string `yaml:"host" hcl:"host"`
+	Port int    `yaml:"port" hcl:"port"`
+}
+
+type decodeConfig struct {
+	decodeBase
+	Name    string            `yaml:"name" hcl:"name"`
+	Timeout time.Duration     `yaml:"timeout" hcl:"timeout"`
+	IP      net.IP            `yaml:"ip" hcl:"ip"`
+	Tags    []string          `yaml:"tags" hcl:"tags"`
+	Labels  map[string]string `yaml:"labels" hcl:"labels"`
+	Server  *decodeServer     `yaml:"server" hcl:"server"`
+	Ignored string            `yaml:"-" hcl:"-"`
+}
+
+func TestDecodeInto(t *testing.T) {
+	tests := []struct {
+		name string
+		typ  TYPE
+		in   string
+		want decodeConfig
+	}{
+		{
+			name: "yaml",
+			typ:  YAML,
+			in:   "id: 7\nNAME: web\ntimeout: 5s\nip: 10.0.0.1\ntags: [a, 1]\nlabels: {k: v}\nserver:\n  host: h\n  port: \"8080\"\nignored: x\n",
+			want: decodeConfig{
+				decodeBase: decodeBase{ID: 7},
+				Name:       "web",
+				Timeout:    5 * time.Second,
+				IP:         net.ParseIP("10.0.0.1"),
+				Tags:       []string{"a", "1"},
+				Labels:     map[string]string{"k": "v"},
+				Server:     &decodeServer{Host: "h", Port: 8080},
+			},
+		},
+		{
+			name: "hcl",
+			typ:  HCL,
+			in:   "id = 7\nname = \"web\"\ntimeout = \"1m\"\ntags = [\"a\"]\nserver {\n  host = \"h\"\n  port = 80\n}\n",
+			want: decodeConfig{
+				decodeBase: decodeBase{ID: 7},
+				Name:       "web",
+				Timeout:    time.Minute,
+				Tags:       []string{"a"},
+				Server:     &decodeServer{Host: "h", Port: 80},
+			},
+		},
+		{
+			name: "json by field name",
+			typ:  JSON,
+			in:   `{"ID": 7, "name": "web", "Server": {"Port": 80.0}}`,
+			want: decodeConfig{
+				decodeBase: decodeBase{ID: 7},
+				Name:       "web",
+				Server:     &decodeServer{Port: 80},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got decodeConfig
+			if err := DecodeInto(strings.NewReader(tt.in), tt.typ, &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeIntoMap(t *testing.T) {
+	got := make(map[string]int)
+	if err := DecodeInto(strings.NewReader("a: 1\nb: \"2\"\n"), YAML, &got); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]int{"a": 1, "b": 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestDecodeIntoErrors(t *testing.T) {
+	const in = "id: x\ntimeout: soon\ntags: [a]\nserver:\n  port: [1]\n"
+	var cfg decodeConfig
+	err := DecodeInto(strings.NewReader(in), YAML, &cfg)
+	derr, ok := err.(*DecodeError)
+	if !ok {
+		t.Fatalf("got %T %v, want a *DecodeError", err, err)
+	}
+	var paths []string
+	for _, fe := range derr.Errors {
+		paths = append(paths, fe.Path)
+	}
+	if want := []string{"ID", "Timeout", "Server.Port"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("got errors at %v, want %v", paths, want)
+	}
+	if !strings.HasPrefix(err.Error(), "3 error(s) decoding:") {
+		t.Errorf("got %q", err)
+	}
+	if !reflect.DeepEqual(cfg.Tags, []string{"a"}) {
+		t.Errorf("valid fields were not decoded: %+v", cfg)
+	}
+
+	if err := DecodeInto(strings.NewReader("a: 1"), YAML, cfg); err == nil {
+		t.Error("expected an error for a non-pointer")
+	}
+}
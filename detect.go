@@ -0,0 +1,179 @@
+package goreflect
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"regexp"
+
+	"github.com/pkg/errors"
+)
+
+// sniffLen is the number of leading bytes DetectType inspects.
+const sniffLen = 4096
+
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+// Sniffer is implemented by codecs that can recognise their format from the
+// leading bytes of a document. Sniff returns a confidence between 0 (not
+// this format) and 1 (certainly this format).
+type Sniffer interface {
+	Sniff(head []byte) float64
+}
+
+// Detection is the outcome of DetectType.
+type Detection struct {
+	Type       TYPE
+	Confidence float64
+}
+
+// DetectType inspects the start of r, and its file name when r has a Name
+// method like *os.File, to guess its format. The returned reader replays
+// every byte of r, including the ones consumed while sniffing, less a
+// leading UTF-8 byte order mark, which the decoders do not accept.
+func DetectType(r io.Reader) (Detection, io.Reader, error) {
+	br := bufio.NewReaderSize(r, sniffLen)
+	head, err := br.Peek(sniffLen)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return Detection{}, br, errors.WithStack(err)
+	}
+	if bytes.HasPrefix(head, utf8BOM) {
+		br.Discard(len(utf8BOM))
+		head = head[len(utf8BOM):]
+	}
+
+	var name string
+	if n, ok := r.(interface{ Name() string }); ok {
+		name = n.Name()
+	}
+
+	d, ok := detect(head, name)
+	if !ok {
+		return d, br, errors.New("unable to detect the format of the input")
+	}
+	return d, br, nil
+}
+
+// MarshalReaderAuto detects the format of in and decodes it like
+// MarshalReader. It returns the detected type.
+func MarshalReaderAuto(in io.Reader, c map[string]interface{}) (TYPE, error) {
+	d, r, err := DetectType(in)
+	if err != nil {
+		return d.Type, err
+	}
+	return d.Type, MarshalReader(r, d.Type, c)
+}
+
+func detect(head []byte, name string) (Detection, bool) {
+	var best Detection
+	found := false
+
+	for _, t := range RegisteredTypes() {
+		c, err := LookupCodec(t)
+		if err != nil {
+			continue
+		}
+		s, ok := c.(Sniffer)
+		if !ok {
+			continue
+		}
+		if conf := s.Sniff(head); conf > best.Confidence {
+			best = Detection{Type: t, Confidence: conf}
+			found = true
+		}
+	}
+
+	if name == "" {
+		return best, found
+	}
+	t, ok := TypeForExtension(name)
+	if !ok {
+		return best, found
+	}
+	switch {
+	case found && best.Type == t:
+		best.Confidence = 1
+	case !found || best.Confidence < 0.9:
+		best = Detection{Type: t, Confidence: 0.9}
+	}
+	return best, true
+}
+
+// significant returns the first line of head that is neither blank nor a
+// comment, along with the remaining bytes.
+func significant(head []byte) ([]byte, []byte) {
+	for len(head) > 0 {
+		var line []byte
+		if i := bytes.IndexByte(head, '\n'); i >= 0 {
+			line, head = head[:i], head[i+1:]
+		} else {
+			line, head = head, nil
+		}
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 || line[0] == '#' || bytes.HasPrefix(line, []byte("//")) {
+			continue
+		}
+		return line, head
+	}
+	return nil, nil
+}
+
+var (
+	hclBlockRe = regexp.MustCompile(`^[A-Za-z_][\w-]*(\s+("[^"]*"|[A-Za-z_][\w-]*))*\s*\{\s*$`)
+	hclAttrRe  = regexp.MustCompile(`^"?[A-Za-z_][\w.-]*"?\s*=\s*\S`)
+	yamlKeyRe  = regexp.MustCompile(`^("[^"]*"|'[^']*'|[^\s:#{}\[\],&*!|>'"%@` + "`" + `][^:#]*?)\s*:(\s|$)`)
+	yamlSeqRe  = regexp.MustCompile(`^-(\s|$)`)
+)
+
+func (jsonCodec) Sniff(head []byte) float64 {
+	head = bytes.TrimSpace(head)
+	if len(head) == 0 || (head[0] != '{' && head[0] != '[') {
+		return 0
+	}
+	if json.Valid(head) {
+		return 1
+	}
+	return 0.8
+}
+
+func (yamlCodec) Sniff(head []byte) float64 {
+	line, _ := significant(head)
+	switch {
+	case line == nil:
+		return 0
+	case bytes.HasPrefix(line, []byte("%YAML")), bytes.HasPrefix(line, []byte("---")):
+		return 0.95
+	case yamlSeqRe.Match(line), yamlKeyRe.Match(line):
+		return 0.6
+	}
+	return 0
+}
+
+func (xmlCodec) Sniff(head []byte) float64 {
+	head = bytes.TrimSpace(head)
+	switch {
+	case bytes.HasPrefix(head, []byte("<?xml")):
+		return 1
+	case bytes.HasPrefix(head, []byte("<")):
+		return 0.8
+	}
+	return 0
+}
+
+func (hclCodec) Sniff(head []byte) float64 {
+	trimmed := bytes.TrimSpace(head)
+	if bytes.HasPrefix(trimmed, []byte("/*")) {
+		return 0.7
+	}
+	line, _ := significant(head)
+	switch {
+	case line == nil:
+		return 0
+	case hclBlockRe.Match(line):
+		return 0.8
+	case hclAttrRe.Match(line):
+		return 0.65
+	}
+	return 0
+}
@@ -0,0 +1,50 @@
+package goreflect
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestDetectType(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want TYPE
+	}{
+		{"json", `{"a": 1}`, JSON},
+		{"json with BOM", "\xef\xbb\xbf{\"a\": 1}", JSON},
+		{"yaml", "a: 1\nb: [1, 2]\n", YAML},
+		{"hcl", "service \"web\" {\n  port = 80\n}\n", HCL},
+		{"toml", "[server]\nport = 80\n", TOML},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, r, err := DetectType(strings.NewReader(tt.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d.Type != tt.want {
+				t.Errorf("got type %d, want %d", d.Type, tt.want)
+			}
+			b, err := ioutil.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := strings.TrimPrefix(tt.in, "\xef\xbb\xbf"); string(b) != want {
+				t.Errorf("replayed %q, want %q", b, want)
+			}
+		})
+	}
+}
+
+func TestMarshalReaderAutoBOM(t *testing.T) {
+	c := make(map[string]interface{})
+	typ, err := MarshalReaderAuto(strings.NewReader("\xef\xbb\xbf{\"a\": 1}"), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typ != JSON || c["a"] != 1.0 {
+		t.Errorf("got %d %v", typ, c)
+	}
+}
@@ -0,0 +1,358 @@
+package goreflect
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ChangeType tells how a value differs between the two sides of a Diff.
+type ChangeType string
+
+const (
+	Added   ChangeType = "added"
+	Removed ChangeType = "removed"
+	Changed ChangeType = "changed"
+)
+
+// Change is one difference found by Diff. Path is made of map keys and
+// struct fields, named by their json tags as encoding/json names them,
+// joined with dots and slice indexes, e.g. "servers[0].port". Old is nil
+// for Added and New for Removed.
+type Change struct {
+	Type ChangeType
+	Path string
+	Old  interface{}
+	New  interface{}
+}
+
+// DiffOptions tunes DiffWith.
+type DiffOptions struct {
+	// IgnoreCase compares map keys case-insensitively, as if both sides
+	// had gone through InsensitivizeMap. Paths use the lower-cased keys.
+	IgnoreCase bool
+	// IgnoreOrder compares slices as multisets: elements are matched with
+	// an equal element anywhere in the other slice, and only unmatched
+	// ones are reported, at their own index.
+	IgnoreOrder bool
+}
+
+// Diff compares a and b, which may be any mix of maps, slices, structs and
+// scalars, and returns where they differ. Numbers are equal when their
+// values are, whatever their types, and structs are keyed by the names of
+// their json tags, or their field names, so decoded JSON compares equal to
+// the struct of plain data it came from. Values met again through a cycle
+// of pointers are taken as equal.
+func Diff(a, b interface{}) []Change {
+	return DiffWith(a, b, DiffOptions{})
+}
+
+// DiffWith is Diff with options.
+func DiffWith(a, b interface{}, opts DiffOptions) []Change {
+	d := &differ{opts: opts, seen: make(map[diffKey]bool)}
+	d.diff("", reflect.ValueOf(a), reflect.ValueOf(b))
+	return d.changes
+}
+
+type differ struct {
+	opts    DiffOptions
+	changes []Change
+	seen    map[diffKey]bool
+}
+
+// diffKey identifies a pair of maps, slices or structs behind pointers
+// already being compared.
+type diffKey struct {
+	a, b   uintptr
+	ta, tb reflect.Type
+}
+
+func (d *differ) add(t ChangeType, path string, a, b reflect.Value) {
+	d.changes = append(d.changes, Change{Type: t, Path: path, Old: diffValue(a), New: diffValue(b)})
+}
+
+func diffValue(v reflect.Value) interface{} {
+	if !v.IsValid() || !v.CanInterface() {
+		return nil
+	}
+	return v.Interface()
+}
+
+// diffIndirect strips pointers and interfaces. A nil one becomes invalid.
+func diffIndirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+func (d *differ) diff(path string, a, b reflect.Value) {
+	a, b = diffIndirect(a), diffIndirect(b)
+	switch {
+	case !a.IsValid() && !b.IsValid():
+		return
+	case !a.IsValid():
+		d.add(Added, path, a, b)
+		return
+	case !b.IsValid():
+		d.add(Removed, path, a, b)
+		return
+	}
+
+	if pa, pb := diffAddr(a), diffAddr(b); pa != 0 && pb != 0 {
+		k := diffKey{pa, pb, a.Type(), b.Type()}
+		if d.seen[k] {
+			return
+		}
+		d.seen[k] = true
+		defer delete(d.seen, k)
+	}
+
+	switch {
+	case a.Kind() == reflect.Map && b.Kind() == reflect.Map:
+		d.diffEntries(path, d.diffKeys(a), d.diffKeys(b))
+	case isObject(a) && isObject(b):
+		d.diffEntries(path, d.objectEntries(a), d.objectEntries(b))
+	case isList(a) && isList(b):
+		if d.opts.IgnoreOrder {
+			d.diffUnordered(path, a, b)
+		} else {
+			d.diffList(path, a, b)
+		}
+	default:
+		if !scalarEqual(a, b) {
+			d.add(Changed, path, a, b)
+		}
+	}
+}
+
+// diffAddr returns the address of the map, slice or addressable struct v,
+// or 0.
+func diffAddr(v reflect.Value) uintptr {
+	switch v.Kind() {
+	case reflect.Map, reflect.Slice:
+		return v.Pointer()
+	case reflect.Struct, reflect.Array:
+		if v.CanAddr() {
+			return v.UnsafeAddr()
+		}
+	}
+	return 0
+}
+
+func isObject(v reflect.Value) bool {
+	return v.Kind() == reflect.Map || (v.Kind() == reflect.Struct && v.Type() != timeType)
+}
+
+func isList(v reflect.Value) bool {
+	return (v.Kind() == reflect.Slice || v.Kind() == reflect.Array) && v.Type().Elem().Kind() != reflect.Uint8
+}
+
+func scalarEqual(a, b reflect.Value) bool {
+	if !a.CanInterface() || !b.CanInterface() {
+		return true
+	}
+	if isNumber(a) && isNumber(b) {
+		if isInteger(a) && isInteger(b) {
+			return integerEqual(a, b)
+		}
+		x, errx := ToFloat64E(a.Interface())
+		y, erry := ToFloat64E(b.Interface())
+		if errx == nil && erry == nil {
+			return x == y
+		}
+	}
+	return reflect.DeepEqual(a.Interface(), b.Interface())
+}
+
+func isNumber(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return v.Type() != durationType
+	}
+	return false
+}
+
+func isInteger(v reflect.Value) bool {
+	return isNumber(v) && v.Kind() != reflect.Float32 && v.Kind() != reflect.Float64
+}
+
+// integerEqual compares the integers a and b without going through
+// float64, which loses precision above 2^53.
+func integerEqual(a, b reflect.Value) bool {
+	signed := func(v reflect.Value) bool { return v.Kind() >= reflect.Int && v.Kind() <= reflect.Int64 }
+	switch {
+	case signed(a) && signed(b):
+		return a.Int() == b.Int()
+	case signed(a):
+		return a.Int() >= 0 && uint64(a.Int()) == b.Uint()
+	case signed(b):
+		return b.Int() >= 0 && uint64(b.Int()) == a.Uint()
+	}
+	return a.Uint() == b.Uint()
+}
+
+// diffKeys returns the entries of the map v by key, lower-cased with
+// IgnoreCase.
+func (d *differ) diffKeys(v reflect.Value) map[string]reflect.Value {
+	m := make(map[string]reflect.Value, v.Len())
+	for _, k := range v.MapKeys() {
+		key := StrVal(k.Interface())
+		if d.opts.IgnoreCase {
+			key = strings.ToLower(key)
+		}
+		m[key] = v.MapIndex(k)
+	}
+	return m
+}
+
+// objectEntries returns the entries of the map or struct v. Struct fields
+// are named like encoding/json does: by their json tag, or their name,
+// skipping "-" and empty omitempty fields and promoting untagged embedded
+// structs.
+func (d *differ) objectEntries(v reflect.Value) map[string]reflect.Value {
+	if v.Kind() == reflect.Map {
+		return d.diffKeys(v)
+	}
+	m := make(map[string]reflect.Value)
+	d.structEntries(v, m)
+	return m
+}
+
+func (d *differ) structEntries(v reflect.Value, m map[string]reflect.Value) {
+	t := v.Type()
+	var embedded []reflect.Value
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, opts, _ := fieldName(t, i, "json")
+		fv := v.Field(i)
+		if f.Anonymous && name == "" {
+			if ev := diffIndirect(fv); ev.IsValid() && ev.Kind() == reflect.Struct {
+				embedded = append(embedded, ev)
+				continue
+			}
+		}
+		if f.PkgPath != "" || name == "-" || (opts["omitempty"] && IsEmpty(diffValue(fv))) {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if d.opts.IgnoreCase {
+			name = strings.ToLower(name)
+		}
+		if _, ok := m[name]; !ok {
+			m[name] = fv
+		}
+	}
+	// promoted fields lose to the fields of the outer struct
+	for _, ev := range embedded {
+		d.structEntries(ev, m)
+	}
+}
+
+func (d *differ) diffEntries(path string, am, bm map[string]reflect.Value) {
+	keys := make([]string, 0, len(am)+len(bm))
+	for k := range am {
+		keys = append(keys, k)
+	}
+	for k := range bm {
+		if _, ok := am[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		d.diff(joinPath(path, k), am[k], bm[k])
+	}
+}
+
+func (d *differ) diffList(path string, a, b reflect.Value) {
+	for i := 0; i < a.Len() || i < b.Len(); i++ {
+		var x, y reflect.Value
+		if i < a.Len() {
+			x = a.Index(i)
+		}
+		if i < b.Len() {
+			y = b.Index(i)
+		}
+		d.diff(fmt.Sprintf("%s[%d]", path, i), x, y)
+	}
+}
+
+func (d *differ) diffUnordered(path string, a, b reflect.Value) {
+	matched := make([]bool, b.Len())
+	var removed []int
+	for i := 0; i < a.Len(); i++ {
+		found := false
+		for j := 0; j < b.Len(); j++ {
+			if matched[j] {
+				continue
+			}
+			sub := &differ{opts: d.opts, seen: d.seen}
+			sub.diff("", a.Index(i), b.Index(j))
+			if len(sub.changes) == 0 {
+				matched[j], found = true, true
+				break
+			}
+		}
+		if !found {
+			removed = append(removed, i)
+		}
+	}
+	for _, i := range removed {
+		d.add(Removed, fmt.Sprintf("%s[%d]", path, i), a.Index(i), reflect.Value{})
+	}
+	for j, ok := range matched {
+		if !ok {
+			d.add(Added, fmt.Sprintf("%s[%d]", path, j), reflect.Value{}, b.Index(j))
+		}
+	}
+}
+
+// FormatDiff renders changes in the style of a unified diff between from
+// and to, for people to read: each change gets an "@@ path @@" header and
+// its values as JSON. It has no line ranges, so patch and git apply cannot
+// use it.
+func FormatDiff(changes []Change, from, to string) string {
+	if len(changes) == 0 {
+		return ""
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "--- %s\n+++ %s\n", from, to)
+	for _, c := range changes {
+		path := c.Path
+		if path == "" {
+			path = "."
+		}
+		fmt.Fprintf(&buf, "@@ %s @@\n", path)
+		if c.Type != Added {
+			writeDiffLines(&buf, "-", c.Old)
+		}
+		if c.Type != Removed {
+			writeDiffLines(&buf, "+", c.New)
+		}
+	}
+	return buf.String()
+}
+
+func writeDiffLines(buf *bytes.Buffer, prefix string, v interface{}) {
+	b, err := json.MarshalIndent(stringKeys(v), "", "  ")
+	text := string(b)
+	if err != nil {
+		text = fmt.Sprint(v)
+	}
+	for _, line := range strings.Split(text, "\n") {
+		buf.WriteString(prefix)
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+}
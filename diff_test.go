@@ -0,0 +1,178 @@
+package goreflect
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type diffNode struct {
+	Name string
+	Next *diffNode
+}
+
+type diffServer struct {
+	Host    string   `json:"host"`
+	Port    int      `json:"port,omitempty"`
+	Tags    []string `json:"tags"`
+	Secret  string   `json:"-"`
+	private int
+}
+
+type diffBase struct {
+	ID   int
+	Name string
+}
+
+type diffEmbed struct {
+	diffBase
+	Name string
+}
+
+func TestDiff(t *testing.T) {
+	cycle := func(name string) *diffNode {
+		n := &diffNode{Name: name}
+		n.Next = n
+		return n
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal([]byte(`{"host":"a","port":80,"tags":["x"]}`), &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		a, b interface{}
+		opts DiffOptions
+		want []Change
+	}{
+		{
+			name: "equal maps",
+			a:    map[string]interface{}{"a": 1, "b": []interface{}{"x"}},
+			b:    map[string]interface{}{"a": 1, "b": []interface{}{"x"}},
+		},
+		{
+			name: "numbers by value",
+			a:    map[string]interface{}{"a": 1, "b": int64(2), "c": uint8(3)},
+			b:    map[string]interface{}{"a": 1.0, "b": 2, "c": float32(3)},
+		},
+		{
+			name: "added removed changed",
+			a:    map[string]interface{}{"a": 1, "b": "x", "c": []interface{}{1, 2}},
+			b:    map[string]interface{}{"a": 2, "d": true, "c": []interface{}{1}},
+			want: []Change{
+				{Type: Changed, Path: "a", Old: 1, New: 2},
+				{Type: Removed, Path: "b", Old: "x"},
+				{Type: Removed, Path: "c[1]", Old: 2},
+				{Type: Added, Path: "d", New: true},
+			},
+		},
+		{
+			name: "ignore case",
+			a:    map[string]interface{}{"Host": "a"},
+			b:    map[string]interface{}{"host": "a"},
+			opts: DiffOptions{IgnoreCase: true},
+		},
+		{
+			name: "ignore order",
+			a:    []interface{}{1, 2, 3},
+			b:    []interface{}{3, 1, 4},
+			opts: DiffOptions{IgnoreOrder: true},
+			want: []Change{
+				{Type: Removed, Path: "[1]", Old: 2},
+				{Type: Added, Path: "[2]", New: 4},
+			},
+		},
+		{
+			name: "struct against decoded json",
+			a:    diffServer{Host: "a", Port: 80, Tags: []string{"x"}, Secret: "s"},
+			b:    decoded,
+		},
+		{
+			name: "struct against map by field name",
+			a:    struct{ A int }{1},
+			b:    map[string]interface{}{"A": 1.0},
+		},
+		{
+			name: "struct omitempty",
+			a:    diffServer{Host: "a", Tags: []string{}},
+			b:    map[string]interface{}{"host": "a", "tags": []interface{}{}, "port": 1},
+			want: []Change{
+				{Type: Added, Path: "port", New: 1},
+			},
+		},
+		{
+			name: "promoted fields lose to outer fields",
+			a:    diffEmbed{diffBase: diffBase{ID: 1, Name: "inner"}, Name: "outer"},
+			b:    map[string]interface{}{"ID": 1, "Name": "outer"},
+		},
+		{
+			name: "structs by json name",
+			a:    struct{ Servers []diffServer }{[]diffServer{{Host: "a", Port: 80}}},
+			b:    struct{ Servers []diffServer }{[]diffServer{{Host: "a", Port: 81}}},
+			want: []Change{
+				{Type: Changed, Path: "Servers[0].port", Old: 80, New: 81},
+			},
+		},
+		{
+			name: "struct against struct and map agree on paths",
+			a:    diffServer{Host: "a", Port: 80},
+			b:    map[string]interface{}{"host": "a", "port": 81, "tags": []string(nil)},
+			want: []Change{
+				{Type: Changed, Path: "port", Old: 80, New: 81},
+			},
+		},
+		{
+			name: "large integers",
+			a:    map[string]interface{}{"i": int64(1<<53 + 1), "u": uint64(1<<63 + 1), "m": int64(-1)},
+			b:    map[string]interface{}{"i": int64(1 << 53), "u": uint64(1<<63 + 1), "m": ^uint64(0)},
+			want: []Change{
+				{Type: Changed, Path: "i", Old: int64(1<<53 + 1), New: int64(1 << 53)},
+				{Type: Changed, Path: "m", Old: int64(-1), New: ^uint64(0)},
+			},
+		},
+		{
+			name: "integer against float",
+			a:    map[string]interface{}{"a": int64(3), "b": uint8(2)},
+			b:    map[string]interface{}{"a": 3.0, "b": 2.5},
+			want: []Change{
+				{Type: Changed, Path: "b", Old: uint8(2), New: 2.5},
+			},
+		},
+		{
+			name: "pointer cycle",
+			a:    cycle("a"),
+			b:    cycle("a"),
+		},
+		{
+			name: "pointer cycle changed",
+			a:    cycle("a"),
+			b:    cycle("b"),
+			want: []Change{
+				{Type: Changed, Path: "Name", Old: "a", New: "b"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DiffWith(tt.a, tt.b, tt.opts)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatDiff(t *testing.T) {
+	changes := Diff(map[string]interface{}{"a": 1, "b": "x"}, map[string]interface{}{"a": 2})
+	got := FormatDiff(changes, "old.yaml", "new.yaml")
+	for _, want := range []string{"--- old.yaml", "+++ new.yaml", "-1", "+2", `-"x"`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in:\n%s", want, got)
+		}
+	}
+	if FormatDiff(nil, "a", "b") != "" {
+		t.Error("expected no output without changes")
+	}
+}
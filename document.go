@@ -0,0 +1,946 @@
+package goreflect
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/hcl/ast"
+	hclparser "github.com/hashicorp/hcl/hcl/parser"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// Document is a YAML or HCL file that can be edited in place. Edits only
+// rewrite the bytes of the values they touch, so comments, blank lines and
+// formatting elsewhere in the file survive a round trip.
+//
+// Paths are lists of keys, matched case-insensitively like the keys
+// produced by MarshalReader. A YAML document must hold a single document:
+// streams with `---` separators are rejected, so that reads and edits
+// always apply to the same one.
+type Document struct {
+	t   TYPE
+	src []byte
+	ed  documentEditor
+}
+
+type documentEditor interface {
+	set(src []byte, path []string, v interface{}) ([]byte, error)
+	delete(src []byte, path []string) ([]byte, error)
+}
+
+// ParseDocument reads a YAML or HCL document from r.
+func ParseDocument(r io.Reader, t TYPE) (*Document, error) {
+	b, err := readAll(r)
+	if err != nil {
+		return nil, err
+	}
+	d := &Document{t: t, src: b}
+	switch t {
+	case YAML:
+		if n, err := countYAMLDocuments(b); err != nil {
+			return nil, err
+		} else if n > 1 {
+			return nil, errors.Errorf("YAML: editable documents hold one document, got a stream of %d", n)
+		}
+		d.ed = yamlEditor{}
+	case HCL:
+		d.ed = hclEditor{}
+	default:
+		return nil, errors.Errorf("%s: editable documents are only supported for YAML and HCL", t)
+	}
+	if _, err := d.Map(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Bytes returns the current contents of the document.
+func (d *Document) Bytes() []byte {
+	return d.src
+}
+
+// WriteTo writes the document to w.
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(d.src)
+	return int64(n), errors.WithStack(err)
+}
+
+// Map decodes the document. Keys keep their case, HCL blocks nest an
+// object per label and repeated HCL blocks decode as a list.
+func (d *Document) Map() (map[string]interface{}, error) {
+	m, err := decodeTree(bytes.NewReader(d.src), d.t)
+	if err != nil {
+		return nil, err
+	}
+	return m.ToMap(), nil
+}
+
+// Get returns the value at path.
+func (d *Document) Get(path ...string) (interface{}, bool) {
+	c, err := d.Map()
+	if err != nil {
+		return nil, false
+	}
+	return lookupPath(c, path)
+}
+
+func lookupPath(c map[string]interface{}, path []string) (interface{}, bool) {
+	var v interface{} = c
+	for _, k := range path {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if k, ok = foldKey(m, k); !ok {
+			return nil, false
+		}
+		v = m[k]
+	}
+	return v, true
+}
+
+// Set stores v at path, creating missing parents. Only the existing value
+// is rewritten; when the key is new it is appended to its parent.
+func (d *Document) Set(v interface{}, path ...string) error {
+	if len(path) == 0 {
+		return errors.New("Set: empty path")
+	}
+	v, err := normalizeValue(v)
+	if err != nil {
+		return err
+	}
+	src, err := d.ed.set(d.src, path, v)
+	if err != nil {
+		return errors.Wrapf(err, "setting %s", strings.Join(path, "."))
+	}
+	return d.replace(src)
+}
+
+// Delete removes the value at path together with the comment lines directly
+// above it. Deleting a missing path is a no-op.
+func (d *Document) Delete(path ...string) error {
+	if len(path) == 0 {
+		return errors.New("Delete: empty path")
+	}
+	src, err := d.ed.delete(d.src, path)
+	if err != nil {
+		return errors.Wrapf(err, "deleting %s", strings.Join(path, "."))
+	}
+	m, err := decodeTree(bytes.NewReader(src), d.t)
+	if err != nil {
+		return errors.Wrapf(err, "%s: edit produced an invalid document", d.t)
+	}
+	if _, ok := lookupPath(m.ToMap(), path); ok {
+		return errors.Errorf("deleting %s: the value cannot be removed in place", strings.Join(path, "."))
+	}
+	d.src = src
+	return nil
+}
+
+// Update edits the document so that it decodes to c, usually the result of
+// Map changed with SetInDictionary or DeepSearch. Values equal to the
+// current ones are left untouched.
+func (d *Document) Update(c map[string]interface{}) error {
+	cur, err := d.Map()
+	if err != nil {
+		return err
+	}
+	return d.update(nil, cur, stringKeys(c).(map[string]interface{}))
+}
+
+func (d *Document) update(path []string, cur, next map[string]interface{}) error {
+	for _, k := range sortedKeys(next) {
+		p := append(append([]string(nil), path...), k)
+		if ck, ok := foldKey(cur, k); ok {
+			p[len(p)-1] = ck
+			om, ok1 := cur[ck].(map[string]interface{})
+			nm, ok2 := next[k].(map[string]interface{})
+			if ok1 && ok2 {
+				if err := d.update(p, om, nm); err != nil {
+					return err
+				}
+				continue
+			}
+			if jsonEqual(cur[ck], next[k]) {
+				continue
+			}
+		}
+		if err := d.Set(next[k], p...); err != nil {
+			return err
+		}
+	}
+	for _, k := range sortedKeys(cur) {
+		if _, ok := foldKey(next, k); !ok {
+			if err := d.Delete(append(append([]string(nil), path...), k)...); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// replace installs src after checking that it still decodes.
+func (d *Document) replace(src []byte) error {
+	if _, err := decodeTree(bytes.NewReader(src), d.t); err != nil {
+		return errors.Wrapf(err, "%s: edit produced an invalid document", d.t)
+	}
+	d.src = src
+	return nil
+}
+
+func foldKey(m map[string]interface{}, key string) (string, bool) {
+	if _, ok := m[key]; ok {
+		return key, true
+	}
+	for _, k := range sortedKeys(m) {
+		if strings.EqualFold(k, key) {
+			return k, true
+		}
+	}
+	return "", false
+}
+
+func jsonEqual(a, b interface{}) bool {
+	ab, err1 := json.Marshal(stringKeys(a))
+	bb, err2 := json.Marshal(stringKeys(b))
+	return err1 == nil && err2 == nil && bytes.Equal(ab, bb)
+}
+
+// normalizeValue reduces v to *OrderedMap, []interface{}, string, int64,
+// float64, bool or nil.
+func normalizeValue(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(stringKeys(v))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	v, err = decodeOrderedJSON(dec)
+	return v, errors.WithStack(err)
+}
+
+// nest wraps v in one map per key.
+func nest(keys []string, v interface{}) interface{} {
+	for i := len(keys) - 1; i >= 0; i-- {
+		m := NewOrderedMap()
+		m.Set(keys[i], v)
+		v = m
+	}
+	return v
+}
+
+type textEdit struct {
+	start, end int
+	text       string
+}
+
+func applyEdits(src []byte, edits []textEdit) []byte {
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start > edits[j].start })
+	out := append([]byte(nil), src...)
+	for _, e := range edits {
+		out = append(out[:e.start], append([]byte(e.text), out[e.end:]...)...)
+	}
+	return out
+}
+
+func lineStart(src []byte, off int) int {
+	return bytes.LastIndexByte(src[:off], '\n') + 1
+}
+
+func lineEnd(src []byte, off int) int {
+	if i := bytes.IndexByte(src[off:], '\n'); i >= 0 {
+		return off + i
+	}
+	return len(src)
+}
+
+func indentOf(src []byte, off int) string {
+	line := src[lineStart(src, off):lineEnd(src, off)]
+	return string(line[:len(line)-len(bytes.TrimLeft(line, " \t"))])
+}
+
+// wholeLines widens [start, end) to full lines when nothing but blanks or
+// a comment shares them.
+func wholeLines(src []byte, start, end int) (int, int) {
+	ls := lineStart(src, start)
+	if len(bytes.TrimSpace(src[ls:start])) == 0 {
+		start = ls
+	}
+	le := lineEnd(src, end)
+	rest := bytes.TrimSpace(src[end:le])
+	if len(rest) == 0 || rest[0] == '#' || bytes.HasPrefix(rest, []byte("//")) {
+		end = le
+		if end < len(src) {
+			end++
+		}
+	}
+	return start, dropBlankLine(src, start, end)
+}
+
+// dropBlankLine extends the removal of the whole lines [start, end) over
+// the blank line that follows them when a blank line also precedes them, so
+// deleting a paragraph does not leave two blank lines behind.
+func dropBlankLine(src []byte, start, end int) int {
+	if start < 2 || src[start-1] != '\n' || src[start-2] != '\n' || end == 0 || src[end-1] != '\n' {
+		return end
+	}
+	if le := lineEnd(src, end); le < len(src) && len(bytes.TrimSpace(src[end:le])) == 0 {
+		return le + 1
+	}
+	return end
+}
+
+// countYAMLDocuments returns the number of documents in the YAML stream
+// src, counting empty ones.
+func countYAMLDocuments(src []byte) (int, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(src))
+	for n := 0; ; n++ {
+		var v interface{}
+		if err := dec.Decode(&v); err == io.EOF {
+			return n, nil
+		} else if err != nil {
+			return 0, errors.WithStack(err)
+		}
+	}
+}
+
+type yamlEditor struct{}
+
+// yamlEntry is a block mapping entry. Lines are indices into yamlLines.
+type yamlEntry struct {
+	key        string
+	line, last int
+	indent     int
+	colon      int // offset just past the ':'
+	value      int // offset of the inline value
+	valueEnd   int // end of the inline value, before any comment
+	comment    string
+}
+
+type yamlLines struct {
+	src    []byte
+	starts []int
+}
+
+var yamlKeyLineRe = regexp.MustCompile(`^(?:"((?:[^"\\]|\\.)*)"|'((?:[^']|'')*)'|([^\s#'"\[\]{}&*!|>%@,\x60-][^#]*?|-[^\s#][^#]*?))[ \t]*:(?:[ \t]|$)`)
+
+func newYAMLLines(src []byte) *yamlLines {
+	l := &yamlLines{src: src}
+	for off := 0; off < len(src); {
+		l.starts = append(l.starts, off)
+		off = lineEnd(src, off) + 1
+	}
+	return l
+}
+
+func (l *yamlLines) text(i int) []byte {
+	return l.src[l.starts[i]:lineEnd(l.src, l.starts[i])]
+}
+
+func (l *yamlLines) end(i int) int {
+	return lineEnd(l.src, l.starts[i])
+}
+
+func (l *yamlLines) indent(i int) int {
+	t := l.text(i)
+	return len(t) - len(bytes.TrimLeft(t, " "))
+}
+
+// blank reports whether line i holds no content: empty lines, comments,
+// document markers and directives.
+func (l *yamlLines) blank(i int) bool {
+	t := bytes.TrimSpace(l.text(i))
+	return len(t) == 0 || t[0] == '#' || t[0] == '%' || bytes.HasPrefix(t, []byte("---")) || bytes.HasPrefix(t, []byte("..."))
+}
+
+// entries returns the mapping entries of the block made of lines [lo, hi).
+// ok is false when the block holds something other than a mapping.
+func (l *yamlLines) entries(lo, hi int) (entries []yamlEntry, ok bool) {
+	child := -1
+	for i := lo; i < hi; i++ {
+		if l.blank(i) {
+			continue
+		}
+		ind := l.indent(i)
+		if child < 0 {
+			child = ind
+		}
+		if ind > child {
+			if len(entries) > 0 {
+				entries[len(entries)-1].last = i
+			}
+			continue
+		}
+		t := l.text(i)[ind:]
+		m := yamlKeyLineRe.FindSubmatchIndex(t)
+		if m == nil {
+			if t[0] == '-' && len(entries) > 0 {
+				// a block sequence may sit at the indentation of its key
+				entries[len(entries)-1].last = i
+				continue
+			}
+			return nil, false
+		}
+		e := yamlEntry{line: i, last: i, indent: ind}
+		switch {
+		case m[2] >= 0:
+			e.key, _ = strconv.Unquote(`"` + string(t[m[2]:m[3]]) + `"`)
+		case m[4] >= 0:
+			e.key = strings.Replace(string(t[m[4]:m[5]]), "''", "'", -1)
+		default:
+			e.key = string(t[m[6]:m[7]])
+		}
+		base := l.starts[i] + ind
+		e.colon = base + bytes.LastIndexByte(t[:m[1]], ':') + 1
+		value, comment := splitYAMLComment(t[e.colon-base:])
+		e.value = e.colon + len(value) - len(bytes.TrimLeft(value, " \t"))
+		e.valueEnd = e.colon + len(bytes.TrimRight(value, " \t"))
+		e.comment = string(comment)
+		entries = append(entries, e)
+	}
+	return entries, true
+}
+
+// splitYAMLComment splits a line at the first # that starts a comment.
+func splitYAMLComment(t []byte) (value, comment []byte) {
+	var quote byte
+	for i, c := range t {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || t[i-1] == ' ' || t[i-1] == '\t'):
+			return t[:i], t[i:]
+		}
+	}
+	return t, nil
+}
+
+// find walks path through the block mappings of the document. It returns
+// the entry at path if found, otherwise the deepest entry reached, or nil
+// for the root, and the keys left to create below it.
+func (l *yamlLines) find(path []string) (target *yamlEntry, rest []string, found bool, err error) {
+	lo, hi := 0, len(l.starts)
+	for i, k := range path {
+		entries, ok := l.entries(lo, hi)
+		if !ok {
+			return target, path[i:], false, errors.New("YAML: parent is not a block mapping")
+		}
+		var next *yamlEntry
+		for j := range entries {
+			if strings.EqualFold(entries[j].key, k) {
+				next = &entries[j]
+				break
+			}
+		}
+		if next == nil {
+			return target, path[i:], false, nil
+		}
+		target = next
+		if i == len(path)-1 {
+			return target, nil, true, nil
+		}
+		if next.value != next.valueEnd {
+			// an inline or flow value, replaced as a whole
+			return target, path[i+1:], false, nil
+		}
+		lo, hi = next.line+1, next.last+1
+	}
+	return target, nil, true, nil
+}
+
+// renderYAML formats v as the value of a key indented by indent. The result
+// starts right after the key's colon.
+func renderYAML(v interface{}, indent int, comment string) (string, error) {
+	b, err := yaml.Marshal(v)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	lines := strings.Split(strings.TrimRight(string(b), "\n"), "\n")
+	pad := strings.Repeat(" ", indent)
+	if comment != "" {
+		comment = " " + comment
+	}
+
+	compound := false
+	switch v := v.(type) {
+	case *OrderedMap:
+		compound = v.Len() > 0
+	case []interface{}:
+		compound = len(v) > 0
+	}
+	if compound {
+		out := comment
+		for _, line := range lines {
+			out += "\n" + pad + "  " + line
+		}
+		return out, nil
+	}
+	out := " " + lines[0] + comment
+	for _, line := range lines[1:] {
+		out += "\n" + pad + line
+	}
+	return out, nil
+}
+
+// editLF runs edit on src with CRLF line endings turned into LF, as the
+// line based YAML edits expect, and turns them back in the result.
+func editLF(src []byte, edit func([]byte) ([]byte, error)) ([]byte, error) {
+	crlf := []byte("\r\n")
+	if !bytes.Contains(src, crlf) {
+		return edit(src)
+	}
+	out, err := edit(bytes.Replace(src, crlf, []byte("\n"), -1))
+	if err != nil {
+		return nil, err
+	}
+	return bytes.Replace(out, []byte("\n"), crlf, -1), nil
+}
+
+func (yamlEditor) set(src []byte, path []string, v interface{}) ([]byte, error) {
+	return editLF(src, func(src []byte) ([]byte, error) { return yamlSet(src, path, v) })
+}
+
+func (yamlEditor) delete(src []byte, path []string) ([]byte, error) {
+	return editLF(src, func(src []byte) ([]byte, error) { return yamlDelete(src, path) })
+}
+
+func yamlSet(src []byte, path []string, v interface{}) ([]byte, error) {
+	l := newYAMLLines(src)
+	target, rest, found, err := l.find(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if found || (target != nil && target.value != target.valueEnd) {
+		if !found {
+			// merge into the inline value of the parent
+			parent, err := yamlInlineValue(src[target.value:target.valueEnd])
+			if err != nil {
+				return nil, err
+			}
+			setOrdered(parent, rest, v)
+			v = parent
+		}
+		text, err := renderYAML(v, target.indent, target.comment)
+		if err != nil {
+			return nil, err
+		}
+		return applyEdits(src, []textEdit{{target.colon, l.end(target.last), text}}), nil
+	}
+
+	// append a new entry to the parent mapping
+	v = nest(rest[1:], v)
+	at, indent := -1, 0
+	if target != nil {
+		at, indent = l.end(target.last), target.indent+2
+		if entries, _ := l.entries(target.line+1, target.last+1); len(entries) > 0 {
+			indent = entries[0].indent
+		}
+	} else {
+		for i := len(l.starts) - 1; i >= 0; i-- {
+			if !l.blank(i) {
+				at = l.end(i)
+				if entries, _ := l.entries(0, i+1); len(entries) > 0 {
+					indent = entries[0].indent
+				}
+				break
+			}
+		}
+	}
+	key, err := yaml.Marshal(rest[0])
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	text, err := renderYAML(v, indent, "")
+	if err != nil {
+		return nil, err
+	}
+	entry := strings.Repeat(" ", indent) + strings.TrimRight(string(key), "\n") + ":" + text
+	if at < 0 {
+		// no content yet, append after any comments
+		at, entry = len(src), entry+"\n"
+		if at > 0 && src[at-1] != '\n' {
+			entry = "\n" + entry
+		}
+	} else {
+		entry = "\n" + entry
+	}
+	return applyEdits(src, []textEdit{{at, at, entry}}), nil
+}
+
+func yamlInlineValue(b []byte) (*OrderedMap, error) {
+	var v interface{}
+	if err := yaml.Unmarshal(b, &v); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if m, ok := stringKeys(v).(map[string]interface{}); ok {
+		return OrderedMapFrom(m), nil
+	}
+	return NewOrderedMap(), nil
+}
+
+// setOrdered stores v at path below m, replacing non-map values on the way.
+func setOrdered(m *OrderedMap, path []string, v interface{}) {
+	for _, k := range path[:len(path)-1] {
+		for _, key := range m.keys {
+			if strings.EqualFold(key, k) {
+				k = key
+				break
+			}
+		}
+		next, ok := m.values[k].(*OrderedMap)
+		if !ok {
+			next = NewOrderedMap()
+			m.Set(k, next)
+		}
+		m = next
+	}
+	k := path[len(path)-1]
+	for _, key := range m.keys {
+		if strings.EqualFold(key, k) {
+			k = key
+			break
+		}
+	}
+	m.Set(k, v)
+}
+
+func yamlDelete(src []byte, path []string) ([]byte, error) {
+	l := newYAMLLines(src)
+	target, rest, found, err := l.find(path)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		if target == nil || target.value == target.valueEnd {
+			return src, nil
+		}
+		// rewrite the inline or flow value holding path
+		parent, err := yamlInlineValue(src[target.value:target.valueEnd])
+		if err != nil {
+			return nil, err
+		}
+		if !deleteOrdered(parent, rest) {
+			return src, nil
+		}
+		text, err := renderYAML(parent, target.indent, target.comment)
+		if err != nil {
+			return nil, err
+		}
+		return applyEdits(src, []textEdit{{target.colon, l.end(target.last), text}}), nil
+	}
+	if len(path) > 1 {
+		// an emptied block mapping would decode as null
+		parent, _, _, _ := l.find(path[:len(path)-1])
+		if entries, _ := l.entries(parent.line+1, parent.last+1); len(entries) == 1 {
+			text, err := renderYAML(NewOrderedMap(), parent.indent, parent.comment)
+			if err != nil {
+				return nil, err
+			}
+			return applyEdits(src, []textEdit{{parent.colon, l.end(parent.last), text}}), nil
+		}
+	}
+	first := target.line
+	for first > 0 {
+		t := bytes.TrimSpace(l.text(first - 1))
+		if len(t) == 0 || t[0] != '#' || l.indent(first-1) != target.indent {
+			break
+		}
+		first--
+	}
+	end := l.end(target.last)
+	if end < len(src) {
+		end++
+	}
+	return applyEdits(src, []textEdit{{l.starts[first], dropBlankLine(src, l.starts[first], end), ""}}), nil
+}
+
+// deleteOrdered removes path below m and reports whether it was there.
+func deleteOrdered(m *OrderedMap, path []string) bool {
+	for i, k := range path {
+		var key string
+		for _, mk := range m.keys {
+			if strings.EqualFold(mk, k) {
+				key = mk
+				break
+			}
+		}
+		if key == "" {
+			return false
+		}
+		if i == len(path)-1 {
+			m.Delete(key)
+			return true
+		}
+		next, ok := m.values[key].(*OrderedMap)
+		if !ok {
+			return false
+		}
+		m = next
+	}
+	return false
+}
+
+type hclEditor struct{}
+
+// hclTarget is the result of looking a path up in an HCL syntax tree.
+type hclTarget struct {
+	obj   *ast.ObjectType // container of items, nil for the root
+	items []*ast.ObjectItem
+	rest  []string // path relative to the container
+}
+
+func parseHCLList(src []byte) (*ast.ObjectList, error) {
+	file, err := hclparser.Parse(src)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	list, ok := file.Node.(*ast.ObjectList)
+	if !ok {
+		return nil, errors.New("HCL: unexpected root node")
+	}
+	return list, nil
+}
+
+// hclFind collects the items of list addressed by path. Items whose keys
+// are a prefix of path are searched recursively.
+func hclFind(list *ast.ObjectList, obj *ast.ObjectType, path []string) hclTarget {
+	t := hclTarget{obj: obj, rest: path}
+	var fallback *hclTarget
+	for _, item := range list.Items {
+		if !hclKeysMatch(item.Keys, path) {
+			continue
+		}
+		if inner, ok := item.Val.(*ast.ObjectType); ok && len(item.Keys) < len(path) {
+			sub := hclFind(inner.List, inner, path[len(item.Keys):])
+			if len(sub.items) > 0 {
+				return sub
+			}
+			if fallback == nil {
+				fallback = &sub
+			}
+			continue
+		}
+		t.items = append(t.items, item)
+	}
+	if len(t.items) == 0 && fallback != nil {
+		return *fallback
+	}
+	return t
+}
+
+func hclKeysMatch(keys []*ast.ObjectKey, path []string) bool {
+	for i, k := range keys {
+		if i == len(path) {
+			break
+		}
+		if !strings.EqualFold(hclKey(k), path[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func hclNodeEnd(n ast.Node) int {
+	switch n := n.(type) {
+	case *ast.LiteralType:
+		return n.Token.Pos.Offset + len(strings.TrimSuffix(n.Token.Text, "\n"))
+	case *ast.ListType:
+		return n.Rbrack.Offset + 1
+	case *ast.ObjectType:
+		return n.Rbrace.Offset + 1
+	}
+	return n.Pos().Offset
+}
+
+// hclItemSpan returns the span of item including its lead comment.
+func hclItemSpan(item *ast.ObjectItem) (int, int) {
+	start := item.Keys[0].Pos().Offset
+	if item.LeadComment != nil && len(item.LeadComment.List) > 0 {
+		start = item.LeadComment.List[0].Start.Offset
+	}
+	return start, hclNodeEnd(item.Val)
+}
+
+var hclIdentRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+func hclKeyText(k string) string {
+	if hclIdentRe.MatchString(k) {
+		return k
+	}
+	return strconv.Quote(k)
+}
+
+// renderHCLItem formats keys and v as an item. Maps are written as blocks
+// and lists of maps as repeated blocks.
+func renderHCLItem(keys []string, v interface{}, indent string) (string, error) {
+	if len(keys) > 1 {
+		v = nest(keys[1:], v)
+	}
+	key := hclKeyText(keys[0])
+	switch val := v.(type) {
+	case *OrderedMap:
+		text, err := renderHCLValue(val, indent)
+		return key + " " + text, err
+	case []interface{}:
+		if isHCLBlockList(val) {
+			blocks := make([]string, len(val))
+			for i, b := range val {
+				text, err := renderHCLItem(keys[:1], b, indent)
+				if err != nil {
+					return "", err
+				}
+				blocks[i] = text
+			}
+			return strings.Join(blocks, "\n\n"+indent), nil
+		}
+	}
+	text, err := renderHCLValue(v, indent)
+	return key + " = " + text, err
+}
+
+func renderHCLValue(v interface{}, indent string) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", errors.New("HCL: null values cannot be written")
+	case string:
+		return strconv.Quote(v), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case []interface{}:
+		elems := make([]string, len(v))
+		multiline := false
+		for i, e := range v {
+			text, err := renderHCLValue(e, indent+"  ")
+			if err != nil {
+				return "", err
+			}
+			elems[i] = text
+			if _, ok := e.(*OrderedMap); ok {
+				multiline = true
+			}
+		}
+		if !multiline {
+			return "[" + strings.Join(elems, ", ") + "]", nil
+		}
+		return "[\n" + indent + "  " + strings.Join(elems, ",\n"+indent+"  ") + ",\n" + indent + "]", nil
+	case *OrderedMap:
+		if v.Len() == 0 {
+			return "{}", nil
+		}
+		out := "{\n"
+		for _, k := range v.keys {
+			text, err := renderHCLItem([]string{k}, v.values[k], indent+"  ")
+			if err != nil {
+				return "", err
+			}
+			out += indent + "  " + text + "\n"
+		}
+		return out + indent + "}", nil
+	}
+	return "", errors.Errorf("HCL: cannot write %T", v)
+}
+
+func (hclEditor) set(src []byte, path []string, v interface{}) ([]byte, error) {
+	list, err := parseHCLList(src)
+	if err != nil {
+		return nil, err
+	}
+	t := hclFind(list, nil, path)
+
+	if len(t.items) == 1 && len(t.items[0].Keys) == len(t.rest) && !isHCLBlockList(v) {
+		// rewrite the value only
+		item := t.items[0]
+		start, end := item.Val.Pos().Offset, hclNodeEnd(item.Val)
+		text, err := renderHCLValue(v, indentOf(src, item.Keys[0].Pos().Offset))
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := v.(*OrderedMap); !ok && !item.Assign.IsValid() {
+			last := item.Keys[len(item.Keys)-1].Token
+			start, text = last.Pos.Offset+len(last.Text), " = "+text
+		}
+		return applyEdits(src, []textEdit{{start, end, text}}), nil
+	}
+
+	if len(t.items) > 0 {
+		// replace a group of items, such as repeated blocks, with one
+		first := t.items[0]
+		start := first.Keys[0].Pos().Offset
+		text, err := renderHCLItem(t.rest, v, indentOf(src, start))
+		if err != nil {
+			return nil, err
+		}
+		edits := []textEdit{{start, hclNodeEnd(first.Val), text}}
+		for _, item := range t.items[1:] {
+			s, e := hclItemSpan(item)
+			s, e = wholeLines(src, s, e)
+			edits = append(edits, textEdit{s, e, ""})
+		}
+		return applyEdits(src, edits), nil
+	}
+
+	// append a new item to the container
+	if t.obj == nil {
+		text, err := renderHCLItem(t.rest, v, "")
+		if err != nil {
+			return nil, err
+		}
+		if len(src) > 0 && src[len(src)-1] != '\n' {
+			text = "\n" + text
+		}
+		return applyEdits(src, []textEdit{{len(src), len(src), text + "\n"}}), nil
+	}
+	rbrace := t.obj.Rbrace.Offset
+	outer := indentOf(src, rbrace)
+	ls := lineStart(src, rbrace)
+	oneLine := len(bytes.TrimSpace(src[ls:rbrace])) > 0
+	indent := outer + "  "
+	if items := t.obj.List.Items; len(items) > 0 && !oneLine {
+		indent = indentOf(src, items[0].Keys[0].Pos().Offset)
+	}
+	text, err := renderHCLItem(t.rest, v, indent)
+	if err != nil {
+		return nil, err
+	}
+	if !oneLine {
+		return applyEdits(src, []textEdit{{ls, ls, indent + text + "\n"}}), nil
+	}
+	start := ls + len(bytes.TrimRight(src[ls:rbrace], " \t"))
+	return applyEdits(src, []textEdit{{start, rbrace, "\n" + indent + text + "\n" + outer}}), nil
+}
+
+func (hclEditor) delete(src []byte, path []string) ([]byte, error) {
+	list, err := parseHCLList(src)
+	if err != nil {
+		return nil, err
+	}
+	var edits []textEdit
+	t := hclFind(list, nil, path)
+	for _, item := range t.items {
+		if len(item.Keys) < len(t.rest) {
+			// a value standing where path expects a block
+			continue
+		}
+		s, e := hclItemSpan(item)
+		s, e = wholeLines(src, s, e)
+		edits = append(edits, textEdit{s, e, ""})
+	}
+	return applyEdits(src, edits), nil
+}
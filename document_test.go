@@ -0,0 +1,175 @@
+package goreflect
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDocumentEdits(t *testing.T) {
+	tests := []struct {
+		name    string
+		typ     TYPE
+		in      string
+		edit    func(d *Document) error
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "yaml set keeps comments",
+			typ:  YAML,
+			in:   "# c\na: 1 # keep\nb: 2\n",
+			edit: func(d *Document) error { return d.Set(5, "a") },
+			want: "# c\na: 5 # keep\nb: 2\n",
+		},
+		{
+			name: "yaml set creates parents",
+			typ:  YAML,
+			in:   "a: 1\n",
+			edit: func(d *Document) error { return d.Set("v", "b", "c") },
+			want: "a: 1\nb:\n  c: v\n",
+		},
+		{
+			name: "yaml delete with comment",
+			typ:  YAML,
+			in:   "b:\n  # about c\n  c: 2\n  d: 3\n",
+			edit: func(d *Document) error { return d.Delete("b", "c") },
+			want: "b:\n  d: 3\n",
+		},
+		{
+			name: "yaml delete last child",
+			typ:  YAML,
+			in:   "a: 1\nb:\n  c: 2\n",
+			edit: func(d *Document) error { return d.Delete("b", "c") },
+			want: "a: 1\nb: {}\n",
+		},
+		{
+			name: "yaml delete from flow mapping",
+			typ:  YAML,
+			in:   "a: {b: 1, c: 2}\n",
+			edit: func(d *Document) error { return d.Delete("a", "b") },
+			want: "a:\n  c: 2\n",
+		},
+		{
+			name: "yaml delete missing",
+			typ:  YAML,
+			in:   "a: [1, 2]\n",
+			edit: func(d *Document) error { return d.Delete("a", "x") },
+			want: "a: [1, 2]\n",
+		},
+		{
+			name:    "yaml delete below sequence",
+			typ:     YAML,
+			in:      "a:\n  - x: 1\n",
+			edit:    func(d *Document) error { return d.Delete("a", "x") },
+			want:    "a:\n  - x: 1\n",
+			wantErr: true,
+		},
+		{
+			name: "yaml set with CRLF",
+			typ:  YAML,
+			in:   "# c\r\na: 1\r\nb:\r\n  c: 2\r\n",
+			edit: func(d *Document) error { return d.Set(3, "b", "c") },
+			want: "# c\r\na: 1\r\nb:\r\n  c: 3\r\n",
+		},
+		{
+			name: "yaml add and delete with CRLF",
+			typ:  YAML,
+			in:   "a: 1\r\nb:\r\n  c: 2\r\n  d: 3\r\n",
+			edit: func(d *Document) error {
+				if err := d.Delete("b", "c"); err != nil {
+					return err
+				}
+				return d.Set("x", "e")
+			},
+			want: "a: 1\r\nb:\r\n  d: 3\r\ne: x\r\n",
+		},
+		{
+			name: "yaml update",
+			typ:  YAML,
+			in:   "# c\na: 1\nb:\n  c: 2\n",
+			edit: func(d *Document) error {
+				m, err := d.Map()
+				if err != nil {
+					return err
+				}
+				m["a"] = 2
+				delete(m["b"].(map[string]interface{}), "c")
+				return d.Update(m)
+			},
+			want: "# c\na: 2\nb: {}\n",
+		},
+		{
+			name: "hcl set in block",
+			typ:  HCL,
+			in:   "# c\nname = \"x\" # keep\n\nservice \"web\" {\n  # port\n  port = 80\n}\n",
+			edit: func(d *Document) error { return d.Set(81, "service", "web", "port") },
+			want: "# c\nname = \"x\" # keep\n\nservice \"web\" {\n  # port\n  port = 81\n}\n",
+		},
+		{
+			name: "hcl delete with comment",
+			typ:  HCL,
+			in:   "service \"web\" {\n  # port\n  port = 80\n  host = \"h\"\n}\n",
+			edit: func(d *Document) error { return d.Delete("service", "web", "port") },
+			want: "service \"web\" {\n  host = \"h\"\n}\n",
+		},
+		{
+			name:    "hcl delete from one-line object",
+			typ:     HCL,
+			in:      "a = { b = 1, c = 2 }\n",
+			edit:    func(d *Document) error { return d.Delete("a", "b") },
+			want:    "a = { b = 1, c = 2 }\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := ParseDocument(strings.NewReader(tt.in), tt.typ)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = tt.edit(d)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if got := string(d.Bytes()); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDocumentGet(t *testing.T) {
+	d, err := ParseDocument(strings.NewReader("service \"web\" {\n  Port = 80\n}\n"), HCL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := d.Get("service", "web", "port"); !ok || v != int64(80) {
+		t.Errorf("got %v %v", v, ok)
+	}
+	if _, ok := d.Get("service", "db"); ok {
+		t.Error("found a missing block")
+	}
+}
+
+func TestParseDocumentYAMLStream(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		wantErr bool
+	}{
+		{"single", "a: 1\n", false},
+		{"leading marker", "---\na: 1\n", false},
+		{"end marker", "a: 1\n...\n", false},
+		{"two documents", "a: 1\n---\nb: 2\n", true},
+		{"empty first document", "---\n---\na: 1\n", true},
+		{"trailing marker", "a: 1\n---\n", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseDocument(strings.NewReader(tt.in), YAML)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
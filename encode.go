@@ -0,0 +1,332 @@
+package goreflect
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/xml"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	xmlNameType       = reflect.TypeOf(xml.Name{})
+)
+
+// EncodeOptions tunes Encode.
+type EncodeOptions struct {
+	// Tag is the struct tag naming fields. It defaults to the lower-cased
+	// format name, e.g. "yaml" when encoding YAML.
+	Tag string
+	// OmitEmpty omits every empty field, not only those tagged omitempty.
+	OmitEmpty bool
+}
+
+// Encode writes v, usually a struct or a pointer to one, to w as t.
+//
+// Fields are named, skipped with "-", omitted when empty with omitempty,
+// and flattened into their parent with inline the same way for every
+// format, using the tag for the format as parsed by scanMultiTag:
+//
+//	type Server struct {
+//		Name string `json:"name" yaml:"name" hcl:"name"`
+//		TLS  *TLS   `yaml:"tls,omitempty" hcl:"tls,omitempty"`
+//	}
+//
+// Durations are written as strings like "5s" and types implementing
+// encoding.TextMarshaler as their text. HCL output writes nested structs
+// and maps as blocks and slices of them as repeated blocks. XML output is
+// rooted at an element named after the type, or its XMLName field, and
+// honors the attr and chardata options. Formats without a null value omit
+// nil fields.
+func Encode(v interface{}, w io.Writer, t TYPE, opts EncodeOptions) error {
+	tag := opts.Tag
+	if tag == "" {
+		tag = strings.ToLower(t.String())
+	}
+	e := &structEncoder{
+		tag:       tag,
+		omitEmpty: opts.OmitEmpty,
+		omitNull:  t != JSON && t != YAML,
+		xml:       t == XML,
+	}
+	out, err := e.encode(reflect.ValueOf(v))
+	if err != nil {
+		return err
+	}
+	m, ok := out.(*OrderedMap)
+	if !ok {
+		return errors.Errorf("Encode: %T does not encode to an object", v)
+	}
+	switch t {
+	case HCL:
+		return encodeHCL(w, m)
+	case XML:
+		return encodeXML(w, xmlRootName(reflect.ValueOf(v), tag), m)
+	}
+	return MarshalWriterOrdered(w, m, t)
+}
+
+// encodeHCL writes m with bare keys, blocks for objects and a blank line
+// around each block.
+func encodeHCL(w io.Writer, m *OrderedMap) error {
+	var buf bytes.Buffer
+	prevBlock := false
+	for i, k := range m.keys {
+		text, err := renderHCLItem([]string{k}, m.values[k], "")
+		if err != nil {
+			return errors.Wrapf(err, "encoding %s", k)
+		}
+		block := strings.HasSuffix(text, "}")
+		if i > 0 && (block || prevBlock) {
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(text)
+		buf.WriteByte('\n')
+		prevBlock = block
+	}
+	_, err := buf.WriteTo(w)
+	return errors.WithStack(err)
+}
+
+// encodeXML writes m as the children of a root element. Keys starting
+// with @ become attributes and #text the character data.
+func encodeXML(w io.Writer, root string, m *OrderedMap) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return errors.WithStack(err)
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "\t")
+	if err := writeXMLElement(enc, root, m); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := enc.Flush(); err != nil {
+		return errors.WithStack(err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return errors.WithStack(err)
+}
+
+func writeXMLElement(enc *xml.Encoder, name string, v interface{}) error {
+	if s, ok := v.([]interface{}); ok {
+		for _, elem := range s {
+			if err := writeXMLElement(enc, name, elem); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	m, ok := v.(*OrderedMap)
+	if ok {
+		for _, k := range m.keys {
+			if strings.HasPrefix(k, "@") {
+				start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: k[1:]}, Value: StrVal(m.values[k])})
+			}
+		}
+	}
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	if ok {
+		for _, k := range m.keys {
+			var err error
+			switch {
+			case strings.HasPrefix(k, "@"):
+			case k == "#text":
+				err = enc.EncodeToken(xml.CharData(StrVal(m.values[k])))
+			default:
+				err = writeXMLElement(enc, k, m.values[k])
+			}
+			if err != nil {
+				return err
+			}
+		}
+	} else if v != nil {
+		if err := enc.EncodeToken(xml.CharData(StrVal(v))); err != nil {
+			return err
+		}
+	}
+	return enc.EncodeToken(start.End())
+}
+
+// xmlRootName names the root element after the XMLName field of v, or its
+// type.
+func xmlRootName(v reflect.Value, tag string) string {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Struct {
+		if f, ok := v.Type().FieldByName("XMLName"); ok && f.Type == xmlNameType && len(f.Index) == 1 {
+			if name, _, _ := fieldName(v.Type(), f.Index[0], tag); name != "" && name != "-" {
+				return name
+			}
+		}
+		if v.Type().Name() != "" {
+			return v.Type().Name()
+		}
+	}
+	return "config"
+}
+
+type structEncoder struct {
+	tag       string
+	omitEmpty bool
+	omitNull  bool
+	xml       bool
+}
+
+// encode reduces v to *OrderedMap, []interface{} and scalars.
+func (e *structEncoder) encode(v reflect.Value) (interface{}, error) {
+	if !v.IsValid() {
+		return nil, nil
+	}
+	switch v.Type() {
+	case durationType:
+		return time.Duration(v.Int()).String(), nil
+	}
+	if v.CanInterface() && v.Type().Implements(textMarshalerType) && (v.Kind() != reflect.Ptr || !v.IsNil()) {
+		b, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(b), errors.WithStack(err)
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return e.encode(v.Elem())
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return v.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint(), nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil, nil
+		}
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			return string(v.Bytes()), nil
+		}
+		s := make([]interface{}, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			elem, err := e.encode(v.Index(i))
+			if err != nil {
+				return nil, errors.Wrapf(err, "[%d]", i)
+			}
+			s = append(s, elem)
+		}
+		return s, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return nil, nil
+		}
+		m := NewOrderedMap()
+		vals := make(map[string]reflect.Value, v.Len())
+		keys := make([]string, 0, v.Len())
+		for _, k := range v.MapKeys() {
+			key := StrVal(k.Interface())
+			vals[key] = v.MapIndex(k)
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			val, err := e.encode(vals[k])
+			if err != nil {
+				return nil, errors.Wrapf(err, "[%s]", k)
+			}
+			if val != nil || !e.omitNull {
+				m.Set(k, val)
+			}
+		}
+		return m, nil
+	case reflect.Struct:
+		m := NewOrderedMap()
+		return m, e.encodeStruct(v, m)
+	}
+	return nil, errors.Errorf("cannot encode %s", v.Type())
+}
+
+func (e *structEncoder) encodeStruct(v reflect.Value, m *OrderedMap) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, opts, err := fieldName(t, i, e.tag)
+		if err != nil {
+			return errors.Wrapf(err, "field %s", f.Name)
+		}
+		if name == "-" {
+			continue
+		}
+		fv := v.Field(i)
+		if (f.Anonymous && name == "") || opts["inline"] || opts["squash"] {
+			if fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					continue
+				}
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct {
+				if err := e.encodeStruct(fv, m); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if f.PkgPath != "" || f.Type == xmlNameType {
+			continue
+		}
+		if (opts["omitempty"] || e.omitEmpty) && isEmptyValue(fv) {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		switch {
+		case e.xml && opts["attr"]:
+			name = "@" + name
+		case e.xml && opts["chardata"]:
+			name = "#text"
+		}
+		val, err := e.encode(fv)
+		if err != nil {
+			return errors.Wrapf(err, "field %s", f.Name)
+		}
+		if val == nil && e.omitNull {
+			continue
+		}
+		m.Set(name, val)
+	}
+	return nil
+}
+
+// isEmptyValue is IsEmpty for values that may not be interfaced, such as
+// fields promoted from unexported embedded structs.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Slice, reflect.Map, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
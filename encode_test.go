@@ -0,0 +1,119 @@
+package goreflect
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+	"time"
+)
+
+type encodeServer struct {
+	Host string `json:"host" yaml:"host" hcl:"host" toml:"host" properties:"host" xml:"host,attr" proto:"host"`
+	Port int    `json:"port" yaml:"port" hcl:"port" toml:"port" properties:"port" xml:"port" proto:"port"`
+}
+
+type encodeConfig struct {
+	Name    string         `json:"name" yaml:"name" hcl:"name" toml:"name" properties:"name" xml:"name" proto:"name"`
+	Timeout time.Duration  `json:"timeout" yaml:"timeout" hcl:"timeout" toml:"timeout" properties:"timeout" xml:"timeout" proto:"timeout"`
+	Tags    []string       `json:"tags,omitempty" yaml:"tags,omitempty" hcl:"tags,omitempty" toml:"tags,omitempty" properties:"tags,omitempty" xml:"tag,omitempty" proto:"tags,omitempty"`
+	Secret  string         `json:"-" yaml:"-" hcl:"-" toml:"-" properties:"-" xml:"-" proto:"-"`
+	Zone    *string        `json:"zone" yaml:"zone" hcl:"zone" toml:"zone" properties:"zone" xml:"zone" proto:"zone"`
+	Servers []encodeServer `json:"servers" yaml:"servers" hcl:"server" toml:"servers" properties:"servers" xml:"server" proto:"server"`
+}
+
+func TestEncode(t *testing.T) {
+	cfg := encodeConfig{
+		Name:    "web",
+		Timeout: 5 * time.Second,
+		Secret:  "s",
+		Servers: []encodeServer{{"a", 80}, {"b", 443}},
+	}
+	tests := []struct {
+		typ  TYPE
+		opts EncodeOptions
+		want string
+	}{
+		{
+			typ:  JSON,
+			want: `{"name":"web","timeout":"5s","zone":null,"servers":[{"host":"a","port":80},{"host":"b","port":443}]}`,
+		},
+		{
+			typ:  YAML,
+			want: "name: web\ntimeout: 5s\nzone: null\nservers:\n- host: a\n  port: 80\n- host: b\n  port: 443\n",
+		},
+		{
+			typ: XML,
+			want: xml.Header + "<encodeConfig>\n\t<name>web</name>\n\t<timeout>5s</timeout>\n" +
+				"\t<server host=\"a\">\n\t\t<port>80</port>\n\t</server>\n" +
+				"\t<server host=\"b\">\n\t\t<port>443</port>\n\t</server>\n</encodeConfig>\n",
+		},
+		{
+			typ: HCL,
+			want: "name = \"web\"\ntimeout = \"5s\"\n\n" +
+				"server {\n  host = \"a\"\n  port = 80\n}\n\n" +
+				"server {\n  host = \"b\"\n  port = 443\n}\n",
+		},
+		{
+			typ: TOML,
+			want: "name = \"web\"\ntimeout = \"5s\"\n\n" +
+				"[[servers]]\n  host = \"a\"\n  port = 80\n\n" +
+				"[[servers]]\n  host = \"b\"\n  port = 443\n",
+		},
+		{
+			typ: PROPERTIES,
+			want: "name = web\nservers.0.host = a\nservers.0.port = 80\n" +
+				"servers.1.host = b\nservers.1.port = 443\ntimeout = 5s\n",
+		},
+		{
+			typ:  PROTO,
+			want: "name: \"web\"\nserver {\n  host: \"a\"\n  port: 80\n}\nserver {\n  host: \"b\"\n  port: 443\n}\ntimeout: \"5s\"\n",
+		},
+		{
+			typ:  YAML,
+			opts: EncodeOptions{OmitEmpty: true},
+			want: "name: web\ntimeout: 5s\nservers:\n- host: a\n  port: 80\n- host: b\n  port: 443\n",
+		},
+		{
+			typ:  YAML,
+			opts: EncodeOptions{Tag: "json"},
+			want: "name: web\ntimeout: 5s\nzone: null\nservers:\n- host: a\n  port: 80\n- host: b\n  port: 443\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.typ.String(), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Encode(cfg, &buf, tt.typ, tt.opts); err != nil {
+				t.Fatal(err)
+			}
+			got := buf.String()
+			if tt.typ == JSON {
+				var compact bytes.Buffer
+				if err := json.Compact(&compact, buf.Bytes()); err != nil {
+					t.Fatal(err)
+				}
+				got = compact.String()
+			}
+			if got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		v    interface{}
+	}{
+		{"scalar", 1},
+		{"channel field", struct{ C chan int }{make(chan int)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Encode(tt.v, &bytes.Buffer{}, JSON, EncodeOptions{}); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -4,10 +4,6 @@ import (
 	"bytes"
 	"encoding/json"
 	"encoding/xml"
-	"fmt"
-	"github.com/hashicorp/hcl"
-	"github.com/hashicorp/hcl/hcl/printer"
-	"github.com/pkg/errors"
 	"gopkg.in/yaml.v2"
 	"io"
 	"io/ioutil"
@@ -24,24 +20,17 @@ const (
 	HCL
 	PROTO
 	TF
+	TOML
+	PROPERTIES
 )
 
+// String returns the name the type's codec was registered under, or
+// Unsupported if there is none.
 func (d TYPE) String() string {
-	// declare an array of strings
-	// ... operator counts how many
-	// items in the array (7)
-	names := [...]string{
-		"JSON",
-		"XML",
-		"YAML"}
-
-	if d < JSON || d > PROTO {
-		panic(errors.New(Unsupported))
+	if name, ok := typeName(d); ok {
+		return name
 	}
-	if d == PROTO {
-		panic(errors.New(Unsupported))
-	}
-	return names[d]
+	return Unsupported
 }
 
 func EncodeXML(v interface{}, w io.Writer) error {
@@ -120,91 +109,59 @@ func ToPrettyJSONString(v interface{}) string {
 	return string(output)
 }
 
+// MarshalReader decodes in with the codec registered for data and stores
+// the result, with lower-cased keys, in c.
 func MarshalReader(in io.Reader, data TYPE, c map[string]interface{}) error {
-	buf := new(bytes.Buffer)
-	if _, err := buf.ReadFrom(in); err != nil {
-		return errors.WithStack(err)
-	}
-	switch data {
-	case YAML:
-		if err := yaml.Unmarshal(buf.Bytes(), &c); err != nil {
-			return errors.WithStack(err)
-		}
+	return MarshalReaderWith(in, data, c, DecodeOptions{})
+}
 
-	case JSON:
-		if err := json.Unmarshal(buf.Bytes(), &c); err != nil {
-			return errors.WithStack(err)
-		}
-	case XML:
-		if err := xml.Unmarshal(buf.Bytes(), &c); err != nil {
-			return errors.WithStack(err)
-		}
-	case HCL:
-		obj, err := hcl.Parse(string(buf.Bytes()))
-		if err != nil {
-			return errors.WithStack(err)
-		}
-		if err = hcl.DecodeObject(&c, obj.Node); err != nil {
-			return errors.WithStack(err)
-		}
-	case TF:
-		dir := prompt("required: absolute path to your .hcldec file")
-		if dir == "" {
-			return errors.New(`found empty path, see: "https://github.com/hashicorp/hcl2/blob/master/cmd/hcldec/spec-format.md"`)
-		}
-		if err := json.Unmarshal(buf.Bytes(), &c); err != nil {
-			return errors.WithStack(err)
-		}
-	}
+// DecodeOptions tunes MarshalReaderWith and MarshalReaderOrdered.
+type DecodeOptions struct {
+	// PreserveCase keeps keys as written instead of lower-casing them.
+	PreserveCase bool
+	// Proto configures the PROTO codec, which needs a message descriptor
+	// to decode.
+	Proto *ProtoOptions
+	// TF configures the TF codec. Without it TF input must be a state
+	// document, as HCL needs TFOptions.SpecFile.
+	TF *TFOptions
+}
 
-	InsensitivizeMap(c)
+// MarshalReaderWith is MarshalReader with options.
+func MarshalReaderWith(in io.Reader, data TYPE, c map[string]interface{}, opts DecodeOptions) error {
+	codec, err := lookupCodecWith(data, opts.Proto, opts.TF)
+	if err != nil {
+		return err
+	}
+	if err := codec.Decode(in, c); err != nil {
+		return err
+	}
+	if !opts.PreserveCase {
+		InsensitivizeMap(c)
+	}
 	return nil
 }
 
+// MarshalWriter encodes c to w with the codec registered for data.
 func MarshalWriter(w io.Writer, c map[string]interface{}, data TYPE) error {
+	return MarshalWriterWith(w, c, data, WriteOptions{})
+}
 
-	switch data {
-	case JSON:
-		b, err := json.MarshalIndent(c, "", "  ")
-		if err != nil {
-			return errors.WithStack(err)
-		}
-		_, err = fmt.Fprintf(w, "%s", b)
-		if err != nil {
-			return errors.WithStack(err)
-		}
-
-	case HCL:
-		b, err := json.Marshal(c)
-		ast, err := hcl.Parse(string(b))
-		if err != nil {
-			return errors.WithStack(err)
-		}
-		err = printer.Fprint(w, ast.Node)
-		if err != nil {
-			return errors.WithStack(err)
-		}
-
-	case YAML:
-		b, err := yaml.Marshal(c)
-		if err != nil {
-			return errors.WithStack(err)
-		}
-
-		if _, err = fmt.Fprintf(w, "%s", b); err != nil {
-			return errors.WithStack(err)
-		}
-	case TF:
-		dir := prompt("please provide an absolute path to directory containing your .tf state files")
-		b, err := runb("terraform", "output", "-state", dir, "-json")
-		if err != nil {
-			return errors.WithStack(err)
-		}
-		_, err = fmt.Fprintf(w, "%s", b)
-		if err != nil {
-			return errors.WithStack(err)
-		}
+// WriteOptions tunes MarshalWriterWith.
+type WriteOptions struct {
+	// Proto configures the PROTO codec, which needs a message descriptor
+	// to write the wire format.
+	Proto *ProtoOptions
+	// TF configures the TF codec, which needs TFOptions.StatePath to
+	// write anything.
+	TF *TFOptions
+}
 
+// MarshalWriterWith is MarshalWriter with options.
+func MarshalWriterWith(w io.Writer, c map[string]interface{}, data TYPE, opts WriteOptions) error {
+	codec, err := lookupCodecWith(data, opts.Proto, opts.TF)
+	if err != nil {
+		return err
 	}
-	return nil
+	return codec.Encode(w, c)
 }
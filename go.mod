@@ -1,12 +1,18 @@
 module github.com/gofunct/goreflect
 
+go 1.13
+
 require (
 	github.com/aokoli/goutils v1.1.0
 	github.com/hashicorp/hcl v1.0.0
 	github.com/hashicorp/hcl2 v0.0.0-20190130225218-89dbc5eb3d9e
 	github.com/imdario/mergo v0.3.7
+	github.com/magiconair/properties v1.8.0
+	github.com/pelletier/go-toml v1.2.0
 	github.com/pkg/errors v0.8.1
-	github.com/spf13/viper v1.3.1
+	github.com/spf13/pflag v1.0.3
 	github.com/zclconf/go-cty v0.0.0-20190201220620-4ca19710f056
 	gopkg.in/yaml.v2 v2.2.2
 )
+
+require github.com/BurntSushi/toml v0.3.1 // indirect
@@ -3,7 +3,6 @@ package goreflect
 import (
 	"encoding/json"
 	"fmt"
-	"github.com/zclconf/go-cty/cty/function/stdlib"
 	"io"
 	"reflect"
 	"strings"
@@ -24,6 +23,12 @@ type JsonDiagWriter struct {
 
 var _ hcl.DiagnosticWriter = &JsonDiagWriter{}
 
+// NewJsonDiagWriter returns a writer that collects diagnostics and writes
+// them to w as one JSON document on Flush.
+func NewJsonDiagWriter(w io.Writer) *JsonDiagWriter {
+	return &JsonDiagWriter{w: w}
+}
+
 func (wr *JsonDiagWriter) WriteDiagnostic(diag *hcl.Diagnostic) error {
 	wr.diags = append(wr.diags, diag)
 	return nil
@@ -100,7 +105,10 @@ func (wr *JsonDiagWriter) Flush() error {
 }
 
 func LoadSpecFile(filename string) (specFileContent, hcl.Diagnostics) {
-	file, diags := parser.ParseHCLFile(filename)
+	// A fresh parser each time, since hclparse.Parser caches files by name
+	// and is not safe for concurrent use; a shared one would serve stale
+	// content when a spec is edited and reloaded.
+	file, diags := hclparse.NewParser().ParseHCLFile(filename)
 	if diags.HasErrors() {
 		return specFileContent{RootSpec: errSpec}, diags
 	}
@@ -118,25 +126,6 @@ func LoadSpecFile(filename string) (specFileContent, hcl.Diagnostics) {
 	}, diags
 }
 
-var specFuncs = map[string]function.Function{
-	"abs":        stdlib.AbsoluteFunc,
-	"coalesce":   stdlib.CoalesceFunc,
-	"concat":     stdlib.ConcatFunc,
-	"hasindex":   stdlib.HasIndexFunc,
-	"int":        stdlib.IntFunc,
-	"jsondecode": stdlib.JSONDecodeFunc,
-	"jsonencode": stdlib.JSONEncodeFunc,
-	"length":     stdlib.LengthFunc,
-	"lower":      stdlib.LowerFunc,
-	"max":        stdlib.MaxFunc,
-	"min":        stdlib.MinFunc,
-	"reverse":    stdlib.ReverseFunc,
-	"strlen":     stdlib.StrlenFunc,
-	"substr":     stdlib.SubstrFunc,
-	"upper":      stdlib.UpperFunc,
-}
-
-var parser = hclparse.NewParser()
 var diagWr hcl.DiagnosticWriter // initialized in init
 
 type specFileContent struct {
@@ -883,9 +872,19 @@ var typeEvalCtx = &hcl.EvalContext{
 	},
 }
 
+// ParseVars parses the value of an hcldec --vars argument: an inline JSON
+// object when it starts with "{", or else the name of an HCL or JSON file
+// of attributes. argIdx numbers inline arguments in diagnostics.
+func ParseVars(arg string, argIdx int) (map[string]cty.Value, hcl.Diagnostics) {
+	if strings.HasPrefix(strings.TrimSpace(arg), "{") {
+		return parseVarsArg(arg, argIdx)
+	}
+	return parseVarsFile(arg)
+}
+
 func parseVarsArg(src string, argIdx int) (map[string]cty.Value, hcl.Diagnostics) {
 	fakeFn := fmt.Sprintf("<vars argument %d>", argIdx)
-	f, diags := parser.ParseJSON([]byte(src), fakeFn)
+	f, diags := hclparse.NewParser().ParseJSON([]byte(src), fakeFn)
 	if f == nil {
 		return nil, diags
 	}
@@ -899,9 +898,9 @@ func parseVarsFile(filename string) (map[string]cty.Value, hcl.Diagnostics) {
 	var diags hcl.Diagnostics
 
 	if strings.HasSuffix(filename, ".json") {
-		f, diags = parser.ParseJSONFile(filename)
+		f, diags = hclparse.NewParser().ParseJSONFile(filename)
 	} else {
-		f, diags = parser.ParseHCLFile(filename)
+		f, diags = hclparse.NewParser().ParseHCLFile(filename)
 	}
 
 	if f == nil {
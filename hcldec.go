@@ -0,0 +1,125 @@
+package goreflect
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcldec"
+	"github.com/hashicorp/hcl2/hclparse"
+	"github.com/pkg/errors"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// DecodeWithSpec decodes the HCL files inputs with the hcldec spec file
+// specFile, as the hcldec tool does. Inputs ending in .json are read as
+// HCL JSON and "-" reads HCL from standard input. The files are merged
+// into one body, so blocks may be spread across them but an attribute may
+// be set only once; with no inputs the spec is applied to an empty body.
+// Expressions are evaluated with the spec's variables and functions,
+// specFuncs, and vars, which take precedence over the spec's variables.
+func DecodeWithSpec(specFile string, inputs []string, vars map[string]cty.Value) (cty.Value, hcl.Diagnostics) {
+	spec, diags := LoadSpecFile(specFile)
+	if diags.HasErrors() {
+		return cty.DynamicVal, diags
+	}
+
+	p := hclparse.NewParser()
+	files := make([]*hcl.File, 0, len(inputs))
+	for _, name := range inputs {
+		var f *hcl.File
+		var fDiags hcl.Diagnostics
+		switch {
+		case name == "-":
+			src, err := ioutil.ReadAll(os.Stdin)
+			if err != nil {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Failed to read standard input",
+					Detail:   err.Error(),
+				})
+				continue
+			}
+			f, fDiags = p.ParseHCL(src, "<stdin>")
+		case strings.HasSuffix(name, ".json"):
+			f, fDiags = p.ParseJSONFile(name)
+		default:
+			f, fDiags = p.ParseHCLFile(name)
+		}
+		diags = append(diags, fDiags...)
+		if f != nil {
+			files = append(files, f)
+		}
+	}
+	if diags.HasErrors() {
+		return cty.DynamicVal, diags
+	}
+
+	val, decDiags := decodeSpec(spec, files, vars)
+	return val, append(diags, decDiags...)
+}
+
+// DecodeWithSpecMap is DecodeWithSpec returning the result as a map, ready
+// for MarshalWriter. Error diagnostics are returned as the error.
+func DecodeWithSpecMap(specFile string, inputs []string, vars map[string]cty.Value) (map[string]interface{}, error) {
+	val, diags := DecodeWithSpec(specFile, inputs, vars)
+	if diags.HasErrors() {
+		return nil, errors.WithStack(diags)
+	}
+	return CtyToMap(val)
+}
+
+// decodeSpec applies spec to the merged bodies of files.
+func decodeSpec(spec specFileContent, files []*hcl.File, vars map[string]cty.Value) (cty.Value, hcl.Diagnostics) {
+	ctx := &hcl.EvalContext{
+		Variables: make(map[string]cty.Value),
+		Functions: make(map[string]function.Function),
+	}
+	for k, v := range specFuncs {
+		ctx.Functions[k] = v
+	}
+	for k, v := range spec.Functions {
+		ctx.Functions[k] = v
+	}
+	for k, v := range spec.Variables {
+		ctx.Variables[k] = v
+	}
+	for k, v := range vars {
+		ctx.Variables[k] = v
+	}
+
+	var body hcl.Body
+	switch len(files) {
+	case 0:
+		body = hcl.EmptyBody()
+	case 1:
+		body = files[0].Body
+	default:
+		body = hcl.MergeFiles(files)
+	}
+	return hcldec.Decode(body, spec.RootSpec, ctx)
+}
+
+// CtyToJSON returns v as JSON.
+func CtyToJSON(v cty.Value) ([]byte, error) {
+	b, err := ctyjson.Marshal(v, v.Type())
+	return b, errors.WithStack(err)
+}
+
+// CtyToMap returns v, which must be an object or a map, as a map of plain
+// Go values.
+func CtyToMap(v cty.Value) (map[string]interface{}, error) {
+	if !v.Type().IsObjectType() && !v.Type().IsMapType() {
+		return nil, errors.Errorf("expected an object, got %s", v.Type().FriendlyName())
+	}
+	b, err := CtyToJSON(v)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]interface{})
+	return m, errors.WithStack(json.Unmarshal(b, &m))
+}
@@ -0,0 +1,136 @@
+package goreflect
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+const hcldecTestSpec = `
+variables {
+  env = "dev"
+}
+
+object {
+  attr "name" {
+    type     = string
+    required = true
+  }
+  attr "env" {
+    type = string
+  }
+  block_list "server" {
+    object {
+      attr "port" {
+        type = number
+      }
+    }
+  }
+}
+`
+
+func TestDecodeWithSpec(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hcldec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	write := func(name, src string) string {
+		name = filepath.Join(dir, name)
+		if err := ioutil.WriteFile(name, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return name
+	}
+	spec := write("spec.hcldec", hcldecTestSpec)
+	main := write("main.hcl", "name = upper(\"web\")\nenv = env\nserver {\n  port = 80\n}\n")
+	extra := write("extra.hcl", "server {\n  port = 443\n}\n")
+	js := write("main.json", `{"name": "json", "server": [{"port": 8080}]}`)
+	dup := write("dup.hcl", "name = \"again\"\n")
+
+	tests := []struct {
+		name   string
+		inputs []string
+		vars   map[string]cty.Value
+		want   map[string]interface{}
+		err    bool
+	}{
+		{
+			name:   "spec variables and functions",
+			inputs: []string{main},
+			want: map[string]interface{}{
+				"name":   "WEB",
+				"env":    "dev",
+				"server": []interface{}{map[string]interface{}{"port": float64(80)}},
+			},
+		},
+		{
+			name:   "vars override the spec",
+			inputs: []string{main},
+			vars:   map[string]cty.Value{"env": cty.StringVal("prod")},
+			want: map[string]interface{}{
+				"name":   "WEB",
+				"env":    "prod",
+				"server": []interface{}{map[string]interface{}{"port": float64(80)}},
+			},
+		},
+		{
+			name:   "blocks merge across files",
+			inputs: []string{main, extra},
+			want: map[string]interface{}{
+				"name": "WEB",
+				"env":  "dev",
+				"server": []interface{}{
+					map[string]interface{}{"port": float64(80)},
+					map[string]interface{}{"port": float64(443)},
+				},
+			},
+		},
+		{
+			name:   "json input",
+			inputs: []string{js},
+			want: map[string]interface{}{
+				"name":   "json",
+				"env":    nil,
+				"server": []interface{}{map[string]interface{}{"port": float64(8080)}},
+			},
+		},
+		{name: "attribute set twice", inputs: []string{main, dup}, err: true},
+		{name: "missing required attribute", err: true},
+		{name: "missing input", inputs: []string{filepath.Join(dir, "nope.hcl")}, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeWithSpecMap(spec, tt.inputs, tt.vars)
+			if tt.err {
+				if err == nil {
+					t.Errorf("expected an error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCtyToMap(t *testing.T) {
+	if _, err := CtyToMap(cty.StringVal("x")); err == nil {
+		t.Error("expected an error for a string")
+	}
+	got, err := CtyToMap(cty.MapVal(map[string]cty.Value{"a": cty.NumberIntVal(1)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]interface{}{"a": float64(1)}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+}
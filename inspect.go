@@ -0,0 +1,154 @@
+package goreflect
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// FieldInfo describes one field of a struct as seen by InspectStruct.
+type FieldInfo struct {
+	// Path is the dotted Go path of the field, e.g. "Base.ID" for a field
+	// promoted from the embedded struct Base.
+	Path string
+	// Index is the index sequence for reflect.Value.FieldByIndex.
+	Index []int
+	// Name is the Go name of the field.
+	Name string
+	// Type is the Go type of the field.
+	Type reflect.Type
+	// Tags maps each tag key to its values, as parsed by scanMultiTag.
+	Tags map[string][]string
+	// Options maps each tag key to the options following the name, e.g.
+	// ["omitempty"] for `json:"name,omitempty"`.
+	Options map[string][]string
+	// Exported reports whether the field is exported.
+	Exported bool
+	// Embedded reports whether the field is an embedded struct.
+	Embedded bool
+}
+
+// TagName returns the name given to the field by the tag key, without
+// options.
+func (f FieldInfo) TagName(key string) (string, bool) {
+	vals := f.Tags[key]
+	if len(vals) == 0 {
+		return "", false
+	}
+	return strings.SplitN(vals[len(vals)-1], ",", 2)[0], true
+}
+
+// HasOption reports whether the tag key carries the option opt.
+func (f FieldInfo) HasOption(key, opt string) bool {
+	for _, o := range f.Options[key] {
+		if o == opt {
+			return true
+		}
+	}
+	return false
+}
+
+type inspected struct {
+	fields []FieldInfo
+	err    error
+}
+
+var inspectCache sync.Map // reflect.Type -> *inspected
+
+// InspectStruct lists the fields of the struct v, or the struct v points
+// to, in declaration order. Embedded structs are listed themselves followed
+// by their fields. Results are cached per type.
+func InspectStruct(v interface{}) ([]FieldInfo, error) {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, errors.Errorf("InspectStruct: expected a struct, got %T", v)
+	}
+
+	if c, ok := inspectCache.Load(t); ok {
+		return copyFields(c.(*inspected).fields), c.(*inspected).err
+	}
+	fields, err := inspectType(t, "", nil, map[reflect.Type]bool{t: true})
+	c, _ := inspectCache.LoadOrStore(t, &inspected{fields, err})
+	return copyFields(c.(*inspected).fields), c.(*inspected).err
+}
+
+func inspectType(t reflect.Type, prefix string, index []int, seen map[reflect.Type]bool) ([]FieldInfo, error) {
+	var fields []FieldInfo
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		info, err := newFieldInfo(t, i, prefix, index)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, info)
+
+		if !f.Anonymous {
+			continue
+		}
+		et := f.Type
+		if et.Kind() == reflect.Ptr {
+			et = et.Elem()
+		}
+		if et.Kind() != reflect.Struct || seen[et] {
+			continue
+		}
+		seen[et] = true
+		nested, err := inspectType(et, info.Path+".", info.Index, seen)
+		delete(seen, et)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, nested...)
+	}
+	return fields, nil
+}
+
+func newFieldInfo(t reflect.Type, i int, prefix string, index []int) (FieldInfo, error) {
+	f := t.Field(i)
+	p := defaultTagCache.field(t, i)
+	if p.err != nil {
+		return FieldInfo{}, errors.Wrapf(p.err, "field %s%s", prefix, f.Name)
+	}
+	info := FieldInfo{
+		Path:     prefix + f.Name,
+		Index:    append(append([]int(nil), index...), i),
+		Name:     f.Name,
+		Type:     f.Type,
+		Tags:     copyTag(p.vals),
+		Options:  make(map[string][]string),
+		Exported: f.PkgPath == "",
+		Embedded: f.Anonymous,
+	}
+	for k, vals := range p.vals {
+		if len(vals) == 0 {
+			continue
+		}
+		if parts := strings.Split(vals[len(vals)-1], ","); len(parts) > 1 {
+			info.Options[k] = parts[1:]
+		}
+	}
+	return info, nil
+}
+
+func copyFields(fields []FieldInfo) []FieldInfo {
+	if fields == nil {
+		return nil
+	}
+	out := make([]FieldInfo, len(fields))
+	for i, f := range fields {
+		f.Index = append([]int(nil), f.Index...)
+		f.Tags = copyTag(f.Tags)
+		opts := make(map[string][]string, len(f.Options))
+		for k, v := range f.Options {
+			opts[k] = append([]string(nil), v...)
+		}
+		f.Options = opts
+		out[i] = f
+	}
+	return out
+}
@@ -0,0 +1,98 @@
+package goreflect
+
+import (
+	"reflect"
+	"testing"
+)
+
+type inspectBase struct {
+	ID string `json:"id" yaml:"id,omitempty"`
+}
+
+type inspectConfig struct {
+	inspectBase
+	Name   string `json:"name,omitempty" env:"NAME" env:"APP_NAME"`
+	secret string
+	Next   *inspectConfig `json:"next"`
+}
+
+// inspectBad is built at run time as vet rejects its tag.
+var inspectBad = reflect.StructOf([]reflect.StructField{
+	{Name: "Name", Type: reflect.TypeOf(""), Tag: `json:"name`},
+})
+
+func TestInspectStruct(t *testing.T) {
+	tests := []struct {
+		name  string
+		v     interface{}
+		paths []string
+		err   bool
+	}{
+		{
+			name:  "struct",
+			v:     inspectConfig{},
+			paths: []string{"inspectBase", "inspectBase.ID", "Name", "secret", "Next"},
+		},
+		{
+			name:  "pointer",
+			v:     &inspectConfig{},
+			paths: []string{"inspectBase", "inspectBase.ID", "Name", "secret", "Next"},
+		},
+		{name: "malformed tag", v: reflect.New(inspectBad).Elem().Interface(), err: true},
+		{name: "not a struct", v: map[string]interface{}{}, err: true},
+		{name: "nil", v: nil, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields, err := InspectStruct(tt.v)
+			if tt.err {
+				if err == nil {
+					t.Error("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var paths []string
+			for _, f := range fields {
+				paths = append(paths, f.Path)
+			}
+			if !reflect.DeepEqual(paths, tt.paths) {
+				t.Errorf("got paths %v, want %v", paths, tt.paths)
+			}
+		})
+	}
+}
+
+func TestInspectStructFields(t *testing.T) {
+	fields, err := InspectStruct(inspectConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := fields[1]
+	if !reflect.DeepEqual(id.Index, []int{0, 0}) || id.Type != reflect.TypeOf("") {
+		t.Errorf("got ID %+v", id)
+	}
+	if name, ok := id.TagName("yaml"); !ok || name != "id" || !id.HasOption("yaml", "omitempty") {
+		t.Errorf("got yaml tag %q, %v, options %v", name, ok, id.Options)
+	}
+	if !fields[0].Embedded || fields[0].Exported || fields[3].Exported {
+		t.Errorf("got embedded %v, exported %v, %v", fields[0].Embedded, fields[0].Exported, fields[3].Exported)
+	}
+	name := fields[2]
+	if got, _ := name.TagName("env"); got != "APP_NAME" || len(name.Tags["env"]) != 2 {
+		t.Errorf("got env tags %v", name.Tags["env"])
+	}
+	if name.HasOption("env", "omitempty") || !name.HasOption("json", "omitempty") {
+		t.Errorf("got options %v", name.Options)
+	}
+
+	// results are copies, so the cache can't be changed through them
+	fields[1].Index[0] = 9
+	fields[1].Tags["json"][0] = "changed"
+	again, _ := InspectStruct(inspectConfig{})
+	if again[1].Index[0] != 0 || again[1].Tags["json"][0] != "id" {
+		t.Errorf("cached fields changed: %+v", again[1])
+	}
+}
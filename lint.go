@@ -0,0 +1,258 @@
+package goreflect
+
+import (
+	"fmt"
+	"go/ast"
+	goparser "go/parser"
+	"go/token"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// TagOptions lists the struct tag keys LintTags knows and the options each
+// accepts after the name. A nil list means the value is free-form and its
+// options are not checked. Options ending in "=" accept any value, as in
+// `properties:"port,default=8080"`. Callers may add their own keys.
+var TagOptions = map[string][]string{
+	"json":         {"omitempty", "string", "inline"},
+	"yaml":         {"omitempty", "flow", "inline"},
+	"xml":          {"attr", "chardata", "cdata", "innerxml", "comment", "omitempty", "any", "inline"},
+	"hcl":          {"block", "label", "optional", "attr", "remain", "key", "squash", "decodedFields", "unusedKeys", "omitempty", "inline"},
+	"toml":         {"omitempty", "inline"},
+	"properties":   {"omitempty", "inline", "default=", "layout="},
+	"mapstructure": {"omitempty", "squash", "remain"},
+	"proto":        codecTagOptions,
+	"tf":           codecTagOptions,
+	"protobuf":     nil,
+	"env":          nil,
+	"default":      nil,
+	"flag":         nil,
+	"validate":     nil,
+	"description":  nil,
+}
+
+// codecTagOptions are the options Encode and DecodeInto honor in tags
+// named after a format, for the formats whose encoders read no tags of
+// their own. They are unrelated to the protobuf tags of generated code.
+var codecTagOptions = []string{"omitempty", "inline", "squash"}
+
+// nameTags are the tag keys whose value starts with a field name.
+var nameTags = []string{"json", "yaml", "xml", "hcl", "toml", "properties", "mapstructure"}
+
+// TagDiagnostic is a problem LintTags found in a struct tag.
+type TagDiagnostic struct {
+	File       string `json:"file"`
+	Line       int    `json:"line"`
+	Column     int    `json:"column"`
+	Struct     string `json:"struct"`
+	Field      string `json:"field"`
+	Check      string `json:"check"`
+	Message    string `json:"message"`
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+func (d TagDiagnostic) String() string {
+	s := fmt.Sprintf("%s:%d:%d: %s.%s: %s", d.File, d.Line, d.Column, d.Struct, d.Field, d.Message)
+	if d.Suggestion != "" {
+		s += fmt.Sprintf(" (did you mean %q?)", d.Suggestion)
+	}
+	return s
+}
+
+// LintTags parses the non-test Go files of the package in pkgDir and checks
+// every struct tag for
+//
+//	syntax      tags scanMultiTag cannot parse
+//	duplicate   keys given more than once
+//	unknown     keys missing from TagOptions, with the nearest known key
+//	mismatch    field names that differ between formats, ignoring case,
+//	            underscores and dashes
+//	option      options a key does not accept
+//
+// Diagnostics are sorted by position.
+func LintTags(pkgDir string) ([]TagDiagnostic, error) {
+	fset := token.NewFileSet()
+	pkgs, err := goparser.ParseDir(fset, pkgDir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var diags []TagDiagnostic
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Files {
+			ast.Inspect(file, func(n ast.Node) bool {
+				switch n := n.(type) {
+				case *ast.TypeSpec:
+					if st, ok := n.Type.(*ast.StructType); ok {
+						diags = append(diags, lintStruct(fset, n.Name.Name, st)...)
+						// nested anonymous structs are linted under the outer name
+						return false
+					}
+				case *ast.StructType:
+					diags = append(diags, lintStruct(fset, "struct", n)...)
+					return false
+				}
+				return true
+			})
+		}
+	}
+	sort.Slice(diags, func(i, j int) bool {
+		a, b := diags[i], diags[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return diags, nil
+}
+
+func lintStruct(fset *token.FileSet, name string, st *ast.StructType) []TagDiagnostic {
+	var diags []TagDiagnostic
+	for _, field := range st.Fields.List {
+		ast.Inspect(field.Type, func(n ast.Node) bool {
+			inner, ok := n.(*ast.StructType)
+			if ok {
+				diags = append(diags, lintStruct(fset, name+"."+fieldLabel(field), inner)...)
+			}
+			return !ok
+		})
+		if field.Tag == nil {
+			continue
+		}
+		tag, err := strconv.Unquote(field.Tag.Value)
+		if err != nil {
+			continue
+		}
+		pos := fset.Position(field.Tag.Pos())
+		report := func(check, msg, suggestion string) {
+			diags = append(diags, TagDiagnostic{
+				File:       pos.Filename,
+				Line:       pos.Line,
+				Column:     pos.Column,
+				Struct:     name,
+				Field:      fieldLabel(field),
+				Check:      check,
+				Message:    msg,
+				Suggestion: suggestion,
+			})
+		}
+		lintTag(tag, report)
+	}
+	return diags
+}
+
+func lintTag(tag string, report func(check, msg, suggestion string)) {
+	vals, err := scanMultiTag(tag)
+	if err != nil {
+		report("syntax", err.Error(), "")
+		return
+	}
+
+	known := make([]string, 0, len(TagOptions))
+	for k := range TagOptions {
+		known = append(known, k)
+	}
+	sort.Strings(known)
+
+	keys := make([]string, 0, len(vals))
+	for k := range vals {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if len(vals[key]) > 1 {
+			report("duplicate", fmt.Sprintf("key %q is given %d times", key, len(vals[key])), "")
+		}
+		allowed, ok := TagOptions[key]
+		if !ok {
+			report("unknown", fmt.Sprintf("unknown key %q", key), closeEnough(key, known))
+			continue
+		}
+		if allowed == nil {
+			continue
+		}
+		for _, val := range vals[key] {
+			for _, opt := range strings.Split(val, ",")[1:] {
+				if opt == "" || optionAllowed(opt, allowed) {
+					continue
+				}
+				report("option", fmt.Sprintf("%s does not accept option %q", key, opt), closeEnough(opt, allowed))
+			}
+		}
+	}
+
+	var first, firstKey string
+	for _, key := range nameTags {
+		v := vals[key]
+		if len(v) == 0 {
+			continue
+		}
+		name := strings.SplitN(v[len(v)-1], ",", 2)[0]
+		if key == "xml" {
+			name = name[strings.LastIndex(name, ">")+1:]
+		}
+		if name == "" || name == "-" {
+			continue
+		}
+		if first == "" {
+			first, firstKey = name, key
+			continue
+		}
+		if normalizeTagName(name) != normalizeTagName(first) {
+			report("mismatch", fmt.Sprintf("%s name %q does not match %s name %q", key, name, firstKey, first), "")
+		}
+	}
+}
+
+func optionAllowed(opt string, allowed []string) bool {
+	for _, a := range allowed {
+		if opt == a || (strings.HasSuffix(a, "=") && strings.HasPrefix(opt, a)) {
+			return true
+		}
+	}
+	return false
+}
+
+// closeEnough returns the choice nearest to s if it is a plausible typo.
+func closeEnough(s string, choices []string) string {
+	choice, dist := ClosestChoice(s, choices)
+	if choice == "" || dist > 2 || dist >= len(s) {
+		return ""
+	}
+	return choice
+}
+
+func normalizeTagName(s string) string {
+	return strings.ToLower(strings.NewReplacer("_", "", "-", "").Replace(s))
+}
+
+func fieldLabel(f *ast.Field) string {
+	if len(f.Names) > 0 {
+		names := make([]string, len(f.Names))
+		for i, n := range f.Names {
+			names[i] = n.Name
+		}
+		return strings.Join(names, ",")
+	}
+	t := f.Type
+	if star, ok := t.(*ast.StarExpr); ok {
+		t = star.X
+	}
+	switch t := t.(type) {
+	case *ast.Ident:
+		return t.Name
+	case *ast.SelectorExpr:
+		return t.Sel.Name
+	}
+	return "?"
+}
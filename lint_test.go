@@ -0,0 +1,107 @@
+package goreflect
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLintTags(t *testing.T) {
+	tests := []struct {
+		name string
+		tag  string
+		want []TagDiagnostic
+	}{
+		{
+			name: "clean",
+			tag:  `json:"port,omitempty" yaml:"port" proto:"port,omitempty" tf:",squash" env:"PORT"`,
+		},
+		{
+			name: "syntax",
+			tag:  `json:"port`,
+			want: []TagDiagnostic{{Check: "syntax"}},
+		},
+		{
+			name: "duplicate",
+			tag:  `env:"PORT" env:"APP_PORT"`,
+			want: []TagDiagnostic{{Check: "duplicate", Message: `key "env" is given 2 times`}},
+		},
+		{
+			name: "unknown",
+			tag:  `jsno:"port"`,
+			want: []TagDiagnostic{{Check: "unknown", Message: `unknown key "jsno"`, Suggestion: "json"}},
+		},
+		{
+			name: "mismatch",
+			tag:  `json:"listen_port" yaml:"listen-port" toml:"port"`,
+			want: []TagDiagnostic{{Check: "mismatch", Message: `toml name "port" does not match json name "listen_port"`}},
+		},
+		{
+			name: "option",
+			tag:  `json:"port,omitempyt" proto:"port,packed" properties:"port,default=80"`,
+			want: []TagDiagnostic{
+				{Check: "option", Message: `json does not accept option "omitempyt"`, Suggestion: "omitempty"},
+				{Check: "option", Message: `proto does not accept option "packed"`},
+			},
+		},
+		{
+			name: "free-form",
+			tag:  `validate:"min=1,max=2" protobuf:"varint,1,opt,name=port"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "lint")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			src := "package p\n\ntype Config struct {\n\tPort int `" + tt.tag + "`\n}\n"
+			file := filepath.Join(dir, "p.go")
+			if err := ioutil.WriteFile(file, []byte(src), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			diags, err := LintTags(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := range tt.want {
+				tt.want[i].File, tt.want[i].Line, tt.want[i].Column = file, 4, 11
+				tt.want[i].Struct, tt.want[i].Field = "Config", "Port"
+				if tt.want[i].Check == "syntax" && i < len(diags) {
+					tt.want[i].Message = diags[i].Message
+				}
+			}
+			if len(diags) != len(tt.want) || (len(diags) > 0 && !reflect.DeepEqual(diags, tt.want)) {
+				t.Errorf("got %+v, want %+v", diags, tt.want)
+			}
+		})
+	}
+}
+
+func TestLintTagsNested(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	src := "package p\n\ntype Config struct {\n\tServer struct {\n\t\tPort int `jsn:\"port\"`\n\t}\n}\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "p.go"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	test := "package p\n\ntype T struct {\n\tX int `nope:\"x\"`\n}\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "p_test.go"), []byte(test), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	diags, err := LintTags(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diags) != 1 || diags[0].Struct != "Config.Server" || diags[0].Field != "Port" || diags[0].Suggestion != "json" {
+		t.Errorf("got %+v", diags)
+	}
+}
@@ -0,0 +1,287 @@
+package goreflect
+
+import (
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+)
+
+// Source supplies field values to LoadStruct.
+type Source interface {
+	// Name identifies the source in a LoadReport.
+	Name() string
+	// Lookup returns the value of the last field in chain, which lists the
+	// fields leading to it from the struct passed to LoadStruct.
+	Lookup(chain []FieldInfo) (interface{}, bool)
+}
+
+// LoadReport maps the path of each field LoadStruct set, e.g.
+// "Server.Port", to the name of the source that set it last, and the path
+// of each field it skipped to LoadSkipped.
+type LoadReport map[string]string
+
+// LoadSkipped marks the fields LoadStruct leaves alone in a LoadReport:
+// struct fields whose type refers back to an enclosing struct, which would
+// otherwise be loaded without end.
+const LoadSkipped = "skipped"
+
+// LoadStruct fills the struct out points to from its tags and sources:
+//
+//	type Config struct {
+//		Timeout time.Duration `default:"30s" env:"APP_TIMEOUT" flag:"timeout"`
+//	}
+//
+//	report, err := LoadStruct(&cfg, MapSource("config.yaml", c, "yaml"), EnvSource(""), FlagSource(fs))
+//
+// Values are applied lowest precedence first: the default tag, then the
+// sources in the order given, so above a flag wins over the environment,
+// which wins over the config file. Nested structs are filled field by
+// field and values are converted with the To*E functions, like DecodeInto.
+// Strings from tags, the environment and flags are split on commas for
+// slices and into key=value pairs for maps. Every failing field is
+// reported in a *DecodeError, along with the report of what was set.
+func LoadStruct(out interface{}, sources ...Source) (LoadReport, error) {
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return nil, errors.Errorf("LoadStruct: expected a non-nil pointer to a struct, got %T", out)
+	}
+	l := &loader{
+		sources: append([]Source{defaultSource{}}, sources...),
+		report:  make(LoadReport),
+		seen:    map[reflect.Type]bool{rv.Elem().Type(): true},
+	}
+	l.load(rv.Elem(), "", nil)
+	if len(l.errs) > 0 {
+		return l.report, &DecodeError{Errors: l.errs}
+	}
+	return l.report, nil
+}
+
+type loader struct {
+	sources []Source
+	report  LoadReport
+	errs    []*FieldError
+	seen    map[reflect.Type]bool
+}
+
+// load fills the fields of the struct v and reports whether any was set.
+func (l *loader) load(v reflect.Value, prefix string, chain []FieldInfo) bool {
+	t := v.Type()
+	set := false
+	for i := 0; i < t.NumField(); i++ {
+		info, err := newFieldInfo(t, i, prefix, nil)
+		if err != nil {
+			l.errs = append(l.errs, &FieldError{Path: prefix + t.Field(i).Name, Err: errors.Cause(err)})
+			continue
+		}
+		if !info.Exported && !info.Embedded {
+			continue
+		}
+		c := append(chain[:len(chain):len(chain)], info)
+		fv := v.Field(i)
+
+		if st := loadStructType(info.Type); st != nil {
+			if l.seen[st] {
+				l.report[info.Path] = LoadSkipped
+				continue
+			}
+			l.seen[st] = true
+			if fv.Kind() == reflect.Ptr && fv.IsNil() {
+				nv := reflect.New(st)
+				if l.load(nv.Elem(), info.Path+".", c) && fv.CanSet() {
+					fv.Set(nv)
+					set = true
+				}
+			} else if l.load(reflect.Indirect(fv), info.Path+".", c) {
+				set = true
+			}
+			delete(l.seen, st)
+			continue
+		}
+		if !info.Exported || !fv.CanSet() {
+			continue
+		}
+
+		for _, src := range l.sources {
+			in, ok := src.Lookup(c)
+			if !ok {
+				continue
+			}
+			nv := reflect.New(fv.Type()).Elem()
+			d := &structDecoder{}
+			d.decode(info.Path, in, nv)
+			if len(d.errs) > 0 {
+				for _, fe := range d.errs {
+					fe.Err = errors.Wrapf(fe.Err, "from %s", src.Name())
+				}
+				l.errs = append(l.errs, d.errs...)
+				continue
+			}
+			fv.Set(nv)
+			l.report[info.Path] = src.Name()
+			set = true
+		}
+	}
+	return set
+}
+
+// loadStructType returns the struct type t is or points to, unless it is
+// filled as a single value.
+func loadStructType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t == timeType || reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		return nil
+	}
+	return t
+}
+
+// splitValue splits s for slice and map fields.
+func splitValue(f FieldInfo, s string) interface{} {
+	t := f.Type
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return s
+		}
+		if strings.TrimSpace(s) == "" {
+			return []interface{}{}
+		}
+		parts := strings.Split(s, ",")
+		out := make([]interface{}, len(parts))
+		for i, p := range parts {
+			out[i] = strings.TrimSpace(p)
+		}
+		return out
+	case reflect.Map:
+		out := make(map[string]interface{})
+		for _, p := range strings.Split(s, ",") {
+			if strings.TrimSpace(p) == "" {
+				continue
+			}
+			kv := strings.SplitN(p, "=", 2)
+			if len(kv) == 1 {
+				kv = append(kv, "")
+			}
+			out[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+		}
+		return out
+	}
+	return s
+}
+
+type defaultSource struct{}
+
+func (defaultSource) Name() string { return "default" }
+
+func (defaultSource) Lookup(chain []FieldInfo) (interface{}, bool) {
+	f := chain[len(chain)-1]
+	vals := f.Tags["default"]
+	if len(vals) == 0 {
+		return nil, false
+	}
+	return splitValue(f, vals[len(vals)-1]), true
+}
+
+type envSource struct {
+	prefix string
+}
+
+// EnvSource reads fields tagged `env:"NAME"` from the environment variable
+// prefix+NAME. Variables set to the empty string count as set.
+func EnvSource(prefix string) Source {
+	return envSource{prefix}
+}
+
+func (envSource) Name() string { return "env" }
+
+func (s envSource) Lookup(chain []FieldInfo) (interface{}, bool) {
+	f := chain[len(chain)-1]
+	name, ok := f.TagName("env")
+	if !ok || name == "" || name == "-" {
+		return nil, false
+	}
+	v, ok := os.LookupEnv(s.prefix + name)
+	if !ok {
+		return nil, false
+	}
+	return splitValue(f, v), true
+}
+
+type flagSource struct {
+	fs *pflag.FlagSet
+}
+
+// FlagSource reads fields tagged `flag:"name"` from the flags of fs the
+// user set. The flags themselves must be defined by the caller, with any
+// type; their defaults are ignored in favor of the default tag.
+func FlagSource(fs *pflag.FlagSet) Source {
+	return flagSource{fs}
+}
+
+func (flagSource) Name() string { return "flag" }
+
+func (s flagSource) Lookup(chain []FieldInfo) (interface{}, bool) {
+	f := chain[len(chain)-1]
+	name, ok := f.TagName("flag")
+	if !ok || name == "" || name == "-" {
+		return nil, false
+	}
+	fl := s.fs.Lookup(name)
+	if fl == nil || !fl.Changed {
+		return nil, false
+	}
+	v := fl.Value.String()
+	if fl.Value.Type() != "string" && strings.HasPrefix(v, "[") && strings.HasSuffix(v, "]") {
+		// slice and map flags print as [a,b]
+		v = v[1 : len(v)-1]
+	}
+	return splitValue(f, v), true
+}
+
+type mapSource struct {
+	name string
+	c    map[string]interface{}
+	tag  string
+}
+
+// MapSource reads fields from c, a decoded config such as the result of
+// MarshalReader, naming them by tag the same way DecodeInto does.
+func MapSource(name string, c map[string]interface{}, tag string) Source {
+	return mapSource{name, c, tag}
+}
+
+func (s mapSource) Name() string { return s.name }
+
+func (s mapSource) Lookup(chain []FieldInfo) (interface{}, bool) {
+	var cur interface{} = s.c
+	for _, f := range chain {
+		name, _ := f.TagName(s.tag)
+		if name == "-" {
+			return nil, false
+		}
+		if (f.Embedded && name == "") || f.HasOption(s.tag, "inline") || f.HasOption(s.tag, "squash") {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		m, err := objectOf(cur)
+		if err != nil {
+			return nil, false
+		}
+		key, ok := foldKey(m, name)
+		if !ok {
+			return nil, false
+		}
+		cur = m[key]
+	}
+	return cur, cur != nil
+}
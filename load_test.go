@@ -0,0 +1,148 @@
+package goreflect
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+type loadServer struct {
+	Host string `yaml:"host" default:"localhost" env:"HOST"`
+	Port int    `yaml:"port" default:"8080" env:"PORT" flag:"port"`
+}
+
+type loadConfig struct {
+	Name    string            `yaml:"name" default:"app"`
+	Timeout time.Duration     `yaml:"timeout" default:"30s" env:"TIMEOUT" flag:"timeout"`
+	Tags    []string          `yaml:"tags" default:"a,b" env:"TAGS"`
+	Labels  map[string]string `yaml:"labels" env:"LABELS"`
+	Server  loadServer        `yaml:"server"`
+	Next    *loadConfig       `yaml:"next"`
+}
+
+func TestLoadStruct(t *testing.T) {
+	file := map[string]interface{}{
+		"name":   "from-file",
+		"tags":   []interface{}{"x"},
+		"server": map[string]interface{}{"host": "example.com", "port": 9000},
+	}
+	tests := []struct {
+		name   string
+		env    map[string]string
+		flags  []string
+		want   loadConfig
+		report LoadReport
+	}{
+		{
+			name: "defaults and file",
+			want: loadConfig{
+				Name:    "from-file",
+				Timeout: 30 * time.Second,
+				Tags:    []string{"x"},
+				Server:  loadServer{Host: "example.com", Port: 9000},
+			},
+			report: LoadReport{
+				"Name":        "config.yaml",
+				"Timeout":     "default",
+				"Tags":        "config.yaml",
+				"Server.Host": "config.yaml",
+				"Server.Port": "config.yaml",
+				"Next":        LoadSkipped,
+			},
+		},
+		{
+			name:  "env and flags win",
+			env:   map[string]string{"APP_TIMEOUT": "1m", "APP_PORT": "7000", "APP_TAGS": "p,q", "APP_LABELS": "k=v,a=b"},
+			flags: []string{"--port=6000"},
+			want: loadConfig{
+				Name:    "from-file",
+				Timeout: time.Minute,
+				Tags:    []string{"p", "q"},
+				Labels:  map[string]string{"k": "v", "a": "b"},
+				Server:  loadServer{Host: "example.com", Port: 6000},
+			},
+			report: LoadReport{
+				"Name":        "config.yaml",
+				"Timeout":     "env",
+				"Tags":        "env",
+				"Labels":      "env",
+				"Server.Host": "config.yaml",
+				"Server.Port": "flag",
+				"Next":        LoadSkipped,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				os.Setenv(k, v)
+				defer os.Unsetenv(k)
+			}
+			fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+			fs.Int("port", 0, "")
+			fs.Duration("timeout", 0, "")
+			if err := fs.Parse(tt.flags); err != nil {
+				t.Fatal(err)
+			}
+
+			var cfg loadConfig
+			report, err := LoadStruct(&cfg, MapSource("config.yaml", file, "yaml"), EnvSource("APP_"), FlagSource(fs))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(cfg, tt.want) {
+				t.Errorf("got %+v, want %+v", cfg, tt.want)
+			}
+			if !reflect.DeepEqual(report, tt.report) {
+				t.Errorf("got report %v, want %v", report, tt.report)
+			}
+		})
+	}
+}
+
+func TestLoadStructErrors(t *testing.T) {
+	os.Setenv("APP_PORT", "nope")
+	defer os.Unsetenv("APP_PORT")
+	var cfg loadConfig
+	_, err := LoadStruct(&cfg, EnvSource("APP_"))
+	derr, ok := err.(*DecodeError)
+	if !ok || len(derr.Errors) != 1 || derr.Errors[0].Path != "Server.Port" {
+		t.Errorf("got %v", err)
+	}
+
+	if _, err := LoadStruct(cfg); err == nil {
+		t.Error("expected an error for a non-pointer")
+	}
+}
+
+type loadTree struct {
+	Name   string `default:"root"`
+	Parent *loadTree
+	Leaf   struct {
+		Back *loadTree
+		Size int `default:"3"`
+	}
+}
+
+func TestLoadStructRecursive(t *testing.T) {
+	var tree loadTree
+	report, err := LoadStruct(&tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := LoadReport{
+		"Name":      "default",
+		"Parent":    LoadSkipped,
+		"Leaf.Back": LoadSkipped,
+		"Leaf.Size": "default",
+	}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("got report %v, want %v", report, want)
+	}
+	if tree.Name != "root" || tree.Parent != nil || tree.Leaf.Size != 3 {
+		t.Errorf("got %+v", tree)
+	}
+}
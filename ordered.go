@@ -0,0 +1,532 @@
+package goreflect
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/hcl/hcl/ast"
+	hclparser "github.com/hashicorp/hcl/hcl/parser"
+	"github.com/hashicorp/hcl/hcl/printer"
+	"github.com/hashicorp/hcl/hcl/token"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// OrderedMap is a string keyed map that remembers the order in which keys
+// were first set. Nested objects decoded by MarshalReaderOrdered are
+// *OrderedMap as well, and lists are []interface{}.
+type OrderedMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+// NewOrderedMap returns an empty OrderedMap.
+func NewOrderedMap() *OrderedMap {
+	return &OrderedMap{values: make(map[string]interface{})}
+}
+
+// Get returns the value stored under key.
+func (m *OrderedMap) Get(key string) (interface{}, bool) {
+	v, ok := m.values[key]
+	return v, ok
+}
+
+// Set stores value under key. A new key is appended to the key order, an
+// existing one keeps its position.
+func (m *OrderedMap) Set(key string, value interface{}) {
+	if m.values == nil {
+		m.values = make(map[string]interface{})
+	}
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+// Delete removes key.
+func (m *OrderedMap) Delete(key string) {
+	if _, ok := m.values[key]; !ok {
+		return
+	}
+	delete(m.values, key)
+	for i, k := range m.keys {
+		if k == key {
+			m.keys = append(m.keys[:i], m.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// Keys returns the keys in order.
+func (m *OrderedMap) Keys() []string {
+	return append([]string(nil), m.keys...)
+}
+
+// Len returns the number of keys.
+func (m *OrderedMap) Len() int {
+	return len(m.keys)
+}
+
+// ToMap converts m, and every OrderedMap nested in it, to plain maps.
+func (m *OrderedMap) ToMap() map[string]interface{} {
+	out := make(map[string]interface{}, len(m.keys))
+	for _, k := range m.keys {
+		out[k] = unorder(m.values[k])
+	}
+	return out
+}
+
+func unorder(v interface{}) interface{} {
+	switch v := v.(type) {
+	case *OrderedMap:
+		return v.ToMap()
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, val := range v {
+			s[i] = unorder(val)
+		}
+		return s
+	}
+	return v
+}
+
+// OrderedMapFrom converts a plain map to an OrderedMap with sorted keys.
+func OrderedMapFrom(c map[string]interface{}) *OrderedMap {
+	return order(stringKeys(c)).(*OrderedMap)
+}
+
+func order(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := NewOrderedMap()
+		for _, k := range sortedKeys(v) {
+			m.Set(k, order(v[k]))
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, val := range v {
+			s[i] = order(val)
+		}
+		return s
+	}
+	return v
+}
+
+// lowerKeys lower-cases the keys of m and its nested maps, mirroring
+// InsensitivizeMap. When two keys collide the first position and the last
+// value win.
+func (m *OrderedMap) lowerKeys() {
+	keys, values := m.keys, m.values
+	m.keys, m.values = nil, make(map[string]interface{}, len(values))
+	for _, k := range keys {
+		v := values[k]
+		if nested, ok := v.(*OrderedMap); ok {
+			nested.lowerKeys()
+		}
+		m.Set(strings.ToLower(k), v)
+	}
+}
+
+// MarshalJSON writes the keys in order.
+func (m *OrderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, k := range m.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		kb, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		vb, err := json.Marshal(m.values[k])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(kb)
+		buf.WriteByte(':')
+		buf.Write(vb)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON reads a JSON object, keeping the order of its keys.
+func (m *OrderedMap) UnmarshalJSON(b []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	v, err := decodeOrderedJSON(dec)
+	if err != nil {
+		return err
+	}
+	om, ok := v.(*OrderedMap)
+	if !ok {
+		return errors.Errorf("expected a JSON object, got %T", v)
+	}
+	*m = *om
+	return nil
+}
+
+// MarshalYAML writes the keys in order.
+func (m *OrderedMap) MarshalYAML() (interface{}, error) {
+	ms := make(yaml.MapSlice, 0, len(m.keys))
+	for _, k := range m.keys {
+		ms = append(ms, yaml.MapItem{Key: k, Value: m.values[k]})
+	}
+	return ms, nil
+}
+
+// UnmarshalYAML reads a YAML mapping, keeping the order of its keys.
+func (m *OrderedMap) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var ms yaml.MapSlice
+	if err := unmarshal(&ms); err != nil {
+		return err
+	}
+	*m = *fromMapSlice(ms)
+	return nil
+}
+
+func fromMapSlice(ms yaml.MapSlice) *OrderedMap {
+	m := NewOrderedMap()
+	for _, item := range ms {
+		m.Set(StrVal(item.Key), fromYAMLValue(item.Value))
+	}
+	return m
+}
+
+func fromYAMLValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case yaml.MapSlice:
+		return fromMapSlice(v)
+	case map[interface{}]interface{}:
+		return order(stringKeys(v))
+	case []interface{}:
+		for i, val := range v {
+			v[i] = fromYAMLValue(val)
+		}
+	}
+	return v
+}
+
+func decodeOrderedJSON(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok := tok.(type) {
+	case json.Delim:
+		switch tok {
+		case '{':
+			m := NewOrderedMap()
+			for dec.More() {
+				kt, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				v, err := decodeOrderedJSON(dec)
+				if err != nil {
+					return nil, err
+				}
+				m.Set(kt.(string), v)
+			}
+			_, err := dec.Token()
+			return m, err
+		case '[':
+			s := []interface{}{}
+			for dec.More() {
+				v, err := decodeOrderedJSON(dec)
+				if err != nil {
+					return nil, err
+				}
+				s = append(s, v)
+			}
+			_, err := dec.Token()
+			return s, err
+		}
+	case json.Number:
+		// integral numbers decode as int64 to keep their precision
+		if i, err := tok.Int64(); err == nil {
+			return i, nil
+		}
+		f, err := tok.Float64()
+		return f, errors.WithStack(err)
+	}
+	return tok, nil
+}
+
+// OrderedCodec is implemented by codecs that can decode into and encode
+// from an OrderedMap without losing key order.
+type OrderedCodec interface {
+	DecodeOrdered(r io.Reader) (*OrderedMap, error)
+	EncodeOrdered(w io.Writer, m *OrderedMap) error
+}
+
+func (jsonCodec) DecodeOrdered(r io.Reader) (*OrderedMap, error) {
+	m := NewOrderedMap()
+	b, err := readAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return m, errors.WithStack(m.UnmarshalJSON(b))
+}
+
+func (jsonCodec) EncodeOrdered(w io.Writer, m *OrderedMap) error {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	_, err = fmt.Fprintf(w, "%s", b)
+	return errors.WithStack(err)
+}
+
+func (yamlCodec) DecodeOrdered(r io.Reader) (*OrderedMap, error) {
+	b, err := readAll(r)
+	if err != nil {
+		return nil, err
+	}
+	m := NewOrderedMap()
+	return m, errors.WithStack(yaml.Unmarshal(b, m))
+}
+
+func (yamlCodec) EncodeOrdered(w io.Writer, m *OrderedMap) error {
+	b, err := yaml.Marshal(m)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	_, err = fmt.Fprintf(w, "%s", b)
+	return errors.WithStack(err)
+}
+
+// DecodeOrdered decodes like Decode, so blocks become lists of objects as
+// with hcl.DecodeObject, and orders the keys of every object as they first
+// appear in the source.
+func (hclCodec) DecodeOrdered(r io.Reader) (*OrderedMap, error) {
+	b, err := readAll(r)
+	if err != nil {
+		return nil, err
+	}
+	file, err := hclparser.Parse(b)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	list, ok := file.Node.(*ast.ObjectList)
+	if !ok {
+		return nil, errors.New("HCL: unexpected root node")
+	}
+	c := make(map[string]interface{})
+	if err := hcl.DecodeObject(&c, file); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return hclOrder(c, []*ast.ObjectList{list}).(*OrderedMap), nil
+}
+
+// hclOrder converts the maps in v, decoded from the items of lists, to
+// OrderedMaps keyed in source order. Keys not found in lists go last,
+// sorted.
+func hclOrder(v interface{}, lists []*ast.ObjectList) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		var keys []string
+		children := make(map[string][]*ast.ObjectList)
+		for _, list := range lists {
+			for _, item := range list.Items {
+				k := hclKey(item.Keys[0])
+				if _, ok := children[k]; !ok {
+					keys = append(keys, k)
+				}
+				children[k] = append(children[k], hclItemLists(item)...)
+			}
+		}
+		m := NewOrderedMap()
+		for _, k := range keys {
+			if val, ok := v[k]; ok {
+				m.Set(k, hclOrder(val, children[k]))
+			}
+		}
+		for _, k := range sortedKeys(v) {
+			if _, ok := m.Get(k); !ok {
+				m.Set(k, hclOrder(v[k], nil))
+			}
+		}
+		return m
+	case []map[string]interface{}:
+		s := make([]interface{}, len(v))
+		for i, val := range v {
+			s[i] = hclOrder(val, lists)
+		}
+		return s
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, val := range v {
+			s[i] = hclOrder(val, lists)
+		}
+		return s
+	}
+	return v
+}
+
+// hclItemLists returns the object lists nested in item: the item made of
+// its remaining keys, as for the labels of `service "web" {}`, or the
+// objects of its value.
+func hclItemLists(item *ast.ObjectItem) []*ast.ObjectList {
+	if len(item.Keys) > 1 {
+		rest := &ast.ObjectItem{Keys: item.Keys[1:], Val: item.Val}
+		return []*ast.ObjectList{{Items: []*ast.ObjectItem{rest}}}
+	}
+	return hclNodeLists(item.Val)
+}
+
+func hclNodeLists(n ast.Node) []*ast.ObjectList {
+	switch n := n.(type) {
+	case *ast.ObjectType:
+		return []*ast.ObjectList{n.List}
+	case *ast.ListType:
+		var lists []*ast.ObjectList
+		for _, elem := range n.List {
+			lists = append(lists, hclNodeLists(elem)...)
+		}
+		return lists
+	}
+	return nil
+}
+
+// decodeTree decodes r like MarshalReaderOrdered with case preserved,
+// except that HCL blocks nest an object per label, so that values can be
+// addressed by path as Document and DecodeInto do.
+func decodeTree(r io.Reader, t TYPE) (*OrderedMap, error) {
+	if t != HCL {
+		return MarshalReaderOrdered(r, t, DecodeOptions{PreserveCase: true})
+	}
+	b, err := readAll(r)
+	if err != nil {
+		return nil, err
+	}
+	file, err := hclparser.Parse(b)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	list, ok := file.Node.(*ast.ObjectList)
+	if !ok {
+		return nil, errors.New("HCL: unexpected root node")
+	}
+	return hclObjectList(list), nil
+}
+
+// hclObjectList walks list in source order. A key repeated at the same
+// level, like two blocks of the same type, becomes a list.
+func hclObjectList(list *ast.ObjectList) *OrderedMap {
+	m := NewOrderedMap()
+	for _, item := range list.Items {
+		cur := m
+		for _, k := range item.Keys[:len(item.Keys)-1] {
+			key := hclKey(k)
+			next, ok := cur.values[key].(*OrderedMap)
+			if !ok {
+				next = NewOrderedMap()
+				cur.Set(key, next)
+			}
+			cur = next
+		}
+
+		key := hclKey(item.Keys[len(item.Keys)-1])
+		val := hclValue(item.Val)
+		prev, exists := cur.values[key]
+		switch {
+		case !exists:
+			cur.Set(key, val)
+		case isHCLBlockList(prev):
+			cur.Set(key, append(prev.([]interface{}), val))
+		default:
+			cur.Set(key, []interface{}{prev, val})
+		}
+	}
+	return m
+}
+
+func isHCLBlockList(v interface{}) bool {
+	s, ok := v.([]interface{})
+	if !ok || len(s) == 0 {
+		return false
+	}
+	_, ok = s[0].(*OrderedMap)
+	return ok
+}
+
+func hclKey(k *ast.ObjectKey) string {
+	if k.Token.Type == token.STRING {
+		return StrVal(k.Token.Value())
+	}
+	return k.Token.Text
+}
+
+func hclValue(n ast.Node) interface{} {
+	switch n := n.(type) {
+	case *ast.LiteralType:
+		return n.Token.Value()
+	case *ast.ListType:
+		s := make([]interface{}, 0, len(n.List))
+		for _, elem := range n.List {
+			s = append(s, hclValue(elem))
+		}
+		return s
+	case *ast.ObjectType:
+		return hclObjectList(n.List)
+	}
+	return nil
+}
+
+func (hclCodec) EncodeOrdered(w io.Writer, m *OrderedMap) error {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	file, err := hcl.Parse(string(b))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(printer.Fprint(w, file.Node))
+}
+
+// MarshalReaderOrdered decodes in into an OrderedMap. Codecs implementing
+// OrderedCodec keep the order of the input, the others yield sorted keys.
+func MarshalReaderOrdered(in io.Reader, data TYPE, opts DecodeOptions) (*OrderedMap, error) {
+	codec, err := lookupCodecWith(data, opts.Proto, opts.TF)
+	if err != nil {
+		return nil, err
+	}
+
+	var m *OrderedMap
+	if oc, ok := codec.(OrderedCodec); ok {
+		if m, err = oc.DecodeOrdered(in); err != nil {
+			return nil, err
+		}
+	} else {
+		c := make(map[string]interface{})
+		if err := codec.Decode(in, c); err != nil {
+			return nil, err
+		}
+		m = OrderedMapFrom(c)
+	}
+	if !opts.PreserveCase {
+		m.lowerKeys()
+	}
+	return m, nil
+}
+
+// MarshalWriterOrdered encodes m to w. Codecs implementing OrderedCodec
+// write the keys in order, the others receive m.ToMap().
+func MarshalWriterOrdered(w io.Writer, m *OrderedMap, data TYPE) error {
+	codec, err := LookupCodec(data)
+	if err != nil {
+		return err
+	}
+	if oc, ok := codec.(OrderedCodec); ok {
+		return oc.EncodeOrdered(w, m)
+	}
+	return codec.Encode(w, m.ToMap())
+}
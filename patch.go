@@ -0,0 +1,453 @@
+package goreflect
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// PatchOp is one operation of an RFC 6902 JSON Patch. Op is one of add,
+// remove, replace, move, copy and test; Path and From are JSON Pointers
+// such as "/servers/0/port".
+type PatchOp struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	From  string      `json:"from,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+
+	noValue bool
+}
+
+// MarshalJSON writes the value of add, replace and test operations even
+// when it is null.
+func (op PatchOp) MarshalJSON() ([]byte, error) {
+	type plain PatchOp
+	switch op.Op {
+	case "add", "replace", "test":
+		return json.Marshal(struct {
+			plain
+			Value interface{} `json:"value"`
+		}{plain(op), op.Value})
+	}
+	return json.Marshal(plain(op))
+}
+
+// UnmarshalJSON records whether the value member is present, so that an
+// add, replace or test without one is rejected rather than taken as null.
+func (op *PatchOp) UnmarshalJSON(b []byte) error {
+	type plain PatchOp
+	var p struct {
+		plain
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(b, &p); err != nil {
+		return err
+	}
+	*op = PatchOp(p.plain)
+	if p.Value == nil {
+		op.noValue = true
+		return nil
+	}
+	return json.Unmarshal(p.Value, &op.Value)
+}
+
+// ParsePointer splits an RFC 6901 JSON Pointer into its unescaped tokens.
+// The empty pointer, naming the whole document, has none.
+func ParsePointer(p string) ([]string, error) {
+	if p == "" {
+		return nil, nil
+	}
+	if p[0] != '/' {
+		return nil, errors.Errorf("JSON pointer %q does not start with /", p)
+	}
+	tokens := strings.Split(p[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(t)
+	}
+	return tokens, nil
+}
+
+// FormatPointer joins tokens into a JSON Pointer, escaping them.
+func FormatPointer(tokens ...string) string {
+	var b strings.Builder
+	for _, t := range tokens {
+		b.WriteByte('/')
+		b.WriteString(strings.NewReplacer("~", "~0", "/", "~1").Replace(t))
+	}
+	return b.String()
+}
+
+// ApplyPatch applies the JSON Patch ops to a copy of doc and returns it.
+// Either every operation applies or an error naming the failing one is
+// returned, and doc is never modified. test compares numbers by value, so
+// 1 and 1.0 are equal, but a null only equals a null and objects must have
+// the same keys.
+func ApplyPatch(doc map[string]interface{}, patch []PatchOp) (map[string]interface{}, error) {
+	var out interface{} = stringKeys(DeepCopy(doc))
+	for i, op := range patch {
+		var err error
+		if out, err = applyOp(out, op); err != nil {
+			return nil, errors.Wrapf(err, "patch op %d (%s %s)", i, op.Op, op.Path)
+		}
+	}
+	m, ok := out.(map[string]interface{})
+	if !ok {
+		return nil, errors.Errorf("patch replaced the document with %T", out)
+	}
+	return m, nil
+}
+
+func applyOp(doc interface{}, op PatchOp) (interface{}, error) {
+	path, err := ParsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.noValue {
+			return nil, errors.New("missing value")
+		}
+	}
+	switch op.Op {
+	case "add":
+		return patchAdd(doc, path, stringKeys(DeepCopy(op.Value)))
+	case "remove":
+		out, _, err := patchRemove(doc, path)
+		return out, err
+	case "replace":
+		if len(path) == 0 {
+			return stringKeys(DeepCopy(op.Value)), nil
+		}
+		out, _, err := patchRemove(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		return patchAdd(out, path, stringKeys(DeepCopy(op.Value)))
+	case "test":
+		v, err := pointerGet(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !patchEqual(reflect.ValueOf(v), reflect.ValueOf(op.Value)) {
+			return nil, errors.New("test failed")
+		}
+		return doc, nil
+	case "move", "copy":
+		from, err := ParsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == "move" {
+			if op.From == op.Path {
+				return doc, nil
+			}
+			if strings.HasPrefix(op.Path, op.From+"/") {
+				return nil, errors.Errorf("cannot move %s into itself", op.From)
+			}
+			out, v, err := patchRemove(doc, from)
+			if err != nil {
+				return nil, err
+			}
+			return patchAdd(out, path, v)
+		}
+		v, err := pointerGet(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return patchAdd(doc, path, DeepCopy(v))
+	}
+	return nil, errors.Errorf("unknown op %q", op.Op)
+}
+
+// pointerGet returns the value at path in doc.
+func pointerGet(doc interface{}, path []string) (interface{}, error) {
+	for _, tok := range path {
+		switch n := doc.(type) {
+		case map[string]interface{}:
+			v, ok := n[tok]
+			if !ok {
+				return nil, errors.Errorf("%q not found", tok)
+			}
+			doc = v
+		case []interface{}:
+			i, err := arrayIndex(tok, len(n), false)
+			if err != nil {
+				return nil, err
+			}
+			doc = n[i]
+		default:
+			return nil, errors.Errorf("cannot index %T with %q", doc, tok)
+		}
+	}
+	return doc, nil
+}
+
+// patchAt calls fn with the container holding the last token of path and
+// that token, and stores the container fn returns in its parent. It
+// returns the new doc.
+func patchAt(doc interface{}, path []string, fn func(parent interface{}, tok string) (interface{}, error)) (interface{}, error) {
+	if len(path) == 1 {
+		return fn(doc, path[0])
+	}
+	switch n := doc.(type) {
+	case map[string]interface{}:
+		child, ok := n[path[0]]
+		if !ok {
+			return nil, errors.Errorf("%q not found", path[0])
+		}
+		c, err := patchAt(child, path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		n[path[0]] = c
+		return n, nil
+	case []interface{}:
+		i, err := arrayIndex(path[0], len(n), false)
+		if err != nil {
+			return nil, err
+		}
+		c, err := patchAt(n[i], path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		n[i] = c
+		return n, nil
+	}
+	return nil, errors.Errorf("cannot index %T with %q", doc, path[0])
+}
+
+func patchAdd(doc interface{}, path []string, v interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return v, nil
+	}
+	return patchAt(doc, path, func(parent interface{}, tok string) (interface{}, error) {
+		switch n := parent.(type) {
+		case map[string]interface{}:
+			n[tok] = v
+			return n, nil
+		case []interface{}:
+			i, err := arrayIndex(tok, len(n), true)
+			if err != nil {
+				return nil, err
+			}
+			n = append(n, nil)
+			copy(n[i+1:], n[i:])
+			n[i] = v
+			return n, nil
+		}
+		return nil, errors.Errorf("cannot add %q to %T", tok, parent)
+	})
+}
+
+// patchRemove removes the value at path and returns the new doc and the
+// removed value.
+func patchRemove(doc interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, nil, errors.New("cannot remove the whole document")
+	}
+	var removed interface{}
+	out, err := patchAt(doc, path, func(parent interface{}, tok string) (interface{}, error) {
+		switch n := parent.(type) {
+		case map[string]interface{}:
+			v, ok := n[tok]
+			if !ok {
+				return nil, errors.Errorf("%q not found", tok)
+			}
+			removed = v
+			delete(n, tok)
+			return n, nil
+		case []interface{}:
+			i, err := arrayIndex(tok, len(n), false)
+			if err != nil {
+				return nil, err
+			}
+			removed = n[i]
+			return append(n[:i], n[i+1:]...), nil
+		}
+		return nil, errors.Errorf("cannot remove %q from %T", tok, parent)
+	})
+	return out, removed, err
+}
+
+// patchEqual reports whether a and b are the same JSON value. Numbers are
+// compared by value, but unlike Diff a null never equals a missing key.
+func patchEqual(a, b reflect.Value) bool {
+	a, b = diffIndirect(a), diffIndirect(b)
+	if !a.IsValid() || !b.IsValid() {
+		return !a.IsValid() && !b.IsValid()
+	}
+	switch {
+	case a.Kind() == reflect.Map && b.Kind() == reflect.Map:
+		if a.Len() != b.Len() {
+			return false
+		}
+		bm := make(map[string]reflect.Value, b.Len())
+		for _, k := range b.MapKeys() {
+			bm[StrVal(k.Interface())] = b.MapIndex(k)
+		}
+		for _, k := range a.MapKeys() {
+			bv, ok := bm[StrVal(k.Interface())]
+			if !ok || !patchEqual(a.MapIndex(k), bv) {
+				return false
+			}
+		}
+		return true
+	case isList(a) && isList(b):
+		if a.Len() != b.Len() {
+			return false
+		}
+		for i := 0; i < a.Len(); i++ {
+			if !patchEqual(a.Index(i), b.Index(i)) {
+				return false
+			}
+		}
+		return true
+	}
+	return scalarEqual(a, b)
+}
+
+// arrayIndex parses tok as an index into an array of n elements. With end,
+// n itself and "-", the end of the array, are allowed.
+func arrayIndex(tok string, n int, end bool) (int, error) {
+	if tok == "-" && end {
+		return n, nil
+	}
+	i, err := strconv.Atoi(tok)
+	if err != nil || i < 0 || strings.HasPrefix(tok, "+") || (len(tok) > 1 && tok[0] == '0') {
+		return 0, errors.Errorf("invalid array index %q", tok)
+	}
+	if i > n || (i == n && !end) {
+		return 0, errors.Errorf("array index %d out of range", i)
+	}
+	return i, nil
+}
+
+// ApplyMergePatch applies the RFC 7386 JSON Merge Patch patch to a copy of
+// doc and returns it: objects are merged recursively, null removes a key
+// and any other value replaces it.
+func ApplyMergePatch(doc, patch map[string]interface{}) map[string]interface{} {
+	return mergePatch(stringKeys(DeepCopy(doc)), stringKeys(patch)).(map[string]interface{})
+}
+
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return DeepCopy(patch)
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = make(map[string]interface{})
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+			continue
+		}
+		t[k] = mergePatch(t[k], v)
+	}
+	return t
+}
+
+// CreatePatch returns a JSON Patch turning a into b. Objects are compared
+// key by key and arrays index by index, with elements added or removed at
+// the end.
+func CreatePatch(a, b map[string]interface{}) []PatchOp {
+	return createPatch(nil, stringKeys(a), stringKeys(b), nil)
+}
+
+func createPatch(ops []PatchOp, a, b interface{}, path []string) []PatchOp {
+	sub := func(tok string) []string {
+		return append(path[:len(path):len(path)], tok)
+	}
+	switch x := a.(type) {
+	case map[string]interface{}:
+		y, ok := b.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(x)+len(y))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		for k := range y {
+			if _, ok := x[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			xv, inx := x[k]
+			yv, iny := y[k]
+			switch {
+			case !iny:
+				ops = append(ops, PatchOp{Op: "remove", Path: FormatPointer(sub(k)...)})
+			case !inx:
+				ops = append(ops, PatchOp{Op: "add", Path: FormatPointer(sub(k)...), Value: yv})
+			default:
+				ops = createPatch(ops, xv, yv, sub(k))
+			}
+		}
+		return ops
+	case []interface{}:
+		y, ok := b.([]interface{})
+		if !ok {
+			break
+		}
+		n := len(x)
+		if len(y) < n {
+			n = len(y)
+		}
+		for i := 0; i < n; i++ {
+			ops = createPatch(ops, x[i], y[i], sub(strconv.Itoa(i)))
+		}
+		for i := len(x) - 1; i >= len(y); i-- {
+			ops = append(ops, PatchOp{Op: "remove", Path: FormatPointer(sub(strconv.Itoa(i))...)})
+		}
+		for i := len(x); i < len(y); i++ {
+			ops = append(ops, PatchOp{Op: "add", Path: FormatPointer(sub("-")...), Value: y[i]})
+		}
+		return ops
+	}
+	if !patchEqual(reflect.ValueOf(a), reflect.ValueOf(b)) {
+		ops = append(ops, PatchOp{Op: "replace", Path: FormatPointer(path...), Value: b})
+	}
+	return ops
+}
+
+// CreateMergePatch returns a JSON Merge Patch turning a into b. Removed
+// keys are set to null; arrays are replaced whole.
+func CreateMergePatch(a, b map[string]interface{}) map[string]interface{} {
+	return createMergePatch(stringKeys(a).(map[string]interface{}), stringKeys(b).(map[string]interface{}))
+}
+
+func createMergePatch(a, b map[string]interface{}) map[string]interface{} {
+	patch := make(map[string]interface{})
+	for k := range a {
+		if _, ok := b[k]; !ok {
+			patch[k] = nil
+		}
+	}
+	for k, bv := range b {
+		av, ok := a[k]
+		if !ok {
+			patch[k] = bv
+			continue
+		}
+		am, aok := av.(map[string]interface{})
+		bm, bok := bv.(map[string]interface{})
+		if aok && bok {
+			if sub := createMergePatch(am, bm); len(sub) > 0 {
+				patch[k] = sub
+			}
+			continue
+		}
+		if !patchEqual(reflect.ValueOf(av), reflect.ValueOf(bv)) {
+			patch[k] = bv
+		}
+	}
+	return patch
+}
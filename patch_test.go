@@ -0,0 +1,208 @@
+package goreflect
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestApplyPatch(t *testing.T) {
+	const doc = `{"name":"web","ports":[80,443],"tls":{"enabled":true,"cert":null}}`
+	tests := []struct {
+		name  string
+		patch string
+		want  string
+		err   string
+	}{
+		{
+			name:  "add",
+			patch: `[{"op":"add","path":"/ports/1","value":8080},{"op":"add","path":"/tls/key","value":"k"}]`,
+			want:  `{"name":"web","ports":[80,8080,443],"tls":{"enabled":true,"cert":null,"key":"k"}}`,
+		},
+		{
+			name:  "add to the end",
+			patch: `[{"op":"add","path":"/ports/-","value":8443}]`,
+			want:  `{"name":"web","ports":[80,443,8443],"tls":{"enabled":true,"cert":null}}`,
+		},
+		{
+			name:  "add null",
+			patch: `[{"op":"add","path":"/zone","value":null}]`,
+			want:  `{"name":"web","ports":[80,443],"tls":{"enabled":true,"cert":null},"zone":null}`,
+		},
+		{
+			name:  "remove",
+			patch: `[{"op":"remove","path":"/ports/0"},{"op":"remove","path":"/tls"}]`,
+			want:  `{"name":"web","ports":[443]}`,
+		},
+		{
+			name:  "replace",
+			patch: `[{"op":"replace","path":"/name","value":"db"}]`,
+			want:  `{"name":"db","ports":[80,443],"tls":{"enabled":true,"cert":null}}`,
+		},
+		{
+			name:  "move and copy",
+			patch: `[{"op":"move","from":"/name","path":"/tls/name"},{"op":"copy","from":"/ports/1","path":"/port"}]`,
+			want:  `{"port":443,"ports":[80,443],"tls":{"enabled":true,"cert":null,"name":"web"}}`,
+		},
+		{
+			name:  "test numbers by value",
+			patch: `[{"op":"test","path":"/ports","value":[80.0,443]},{"op":"test","path":"/tls/cert","value":null}]`,
+			want:  doc,
+		},
+		{
+			name:  "test null against a missing key",
+			patch: `[{"op":"test","path":"/tls","value":{"enabled":true}}]`,
+			err:   "test failed",
+		},
+		{
+			name:  "test extra key",
+			patch: `[{"op":"test","path":"/tls","value":{"enabled":true,"cert":null,"key":null}}]`,
+			err:   "test failed",
+		},
+		{
+			name:  "test missing path",
+			patch: `[{"op":"test","path":"/zone","value":null}]`,
+			err:   `"zone" not found`,
+		},
+		{
+			name:  "add without value",
+			patch: `[{"op":"add","path":"/zone"}]`,
+			err:   "patch op 0 (add /zone): missing value",
+		},
+		{
+			name:  "replace without value",
+			patch: `[{"op":"remove","path":"/tls"},{"op":"replace","path":"/name"}]`,
+			err:   "patch op 1 (replace /name): missing value",
+		},
+		{
+			name:  "move into itself",
+			patch: `[{"op":"move","from":"/tls","path":"/tls/x"}]`,
+			err:   "into itself",
+		},
+		{
+			name:  "index out of range",
+			patch: `[{"op":"add","path":"/ports/3","value":1}]`,
+			err:   "out of range",
+		},
+		{
+			name:  "unknown op",
+			patch: `[{"op":"merge","path":"/name"}]`,
+			err:   `unknown op "merge"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var in map[string]interface{}
+			if err := json.Unmarshal([]byte(doc), &in); err != nil {
+				t.Fatal(err)
+			}
+			var patch []PatchOp
+			if err := json.Unmarshal([]byte(tt.patch), &patch); err != nil {
+				t.Fatal(err)
+			}
+			got, err := ApplyPatch(in, patch)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, want %q", err, tt.err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			} else {
+				var want map[string]interface{}
+				if err := json.Unmarshal([]byte(tt.want), &want); err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("got %v, want %v", got, want)
+				}
+			}
+
+			var orig map[string]interface{}
+			if err := json.Unmarshal([]byte(doc), &orig); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(in, orig) {
+				t.Errorf("doc was modified: %v", in)
+			}
+		})
+	}
+}
+
+func TestPatchOpJSON(t *testing.T) {
+	ops := []PatchOp{
+		{Op: "add", Path: "/a", Value: nil},
+		{Op: "remove", Path: "/b"},
+		{Op: "move", From: "/c", Path: "/d"},
+	}
+	b, err := json.Marshal(ops)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `[{"op":"add","path":"/a","value":null},{"op":"remove","path":"/b"},{"op":"move","path":"/d","from":"/c"}]`
+	if string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+	var back []PatchOp
+	if err := json.Unmarshal(b, &back); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ApplyPatch(map[string]interface{}{"b": 1, "c": 2}, back); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCreatePatch(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+	}{
+		{"same", `{"a":1}`, `{"a":1}`},
+		{"keys", `{"a":1,"b":{"c":true}}`, `{"a":2,"b":{"d":"x"},"e":[1]}`},
+		{"arrays", `{"a":[1,2,3],"b":[1]}`, `{"a":[1],"b":[1,{"c":null}]}`},
+		{"null in an array", `{"a":[{"c":null}]}`, `{"a":[{}]}`},
+		{"type change", `{"a":{"b":1}}`, `{"a":[1]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var a, b map[string]interface{}
+			if err := json.Unmarshal([]byte(tt.a), &a); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(tt.b), &b); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := ApplyPatch(a, CreatePatch(a, b))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, b) {
+				t.Errorf("patch: got %v, want %v", got, b)
+			}
+			if tt.a == tt.b && len(CreatePatch(a, b)) > 0 {
+				t.Errorf("got ops for equal documents: %v", CreatePatch(a, b))
+			}
+
+			// a merge patch cannot set null, so only check it without nulls
+			if strings.Contains(tt.b, "null") {
+				return
+			}
+			if got := ApplyMergePatch(a, CreateMergePatch(a, b)); !reflect.DeepEqual(got, b) {
+				t.Errorf("merge patch: got %v, want %v", got, b)
+			}
+		})
+	}
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	doc := map[string]interface{}{"a": 1, "b": map[string]interface{}{"c": 1, "d": 2}, "e": []interface{}{1}}
+	patch := map[string]interface{}{"a": nil, "b": map[string]interface{}{"c": nil, "f": 3}, "e": []interface{}{2}}
+	want := map[string]interface{}{"b": map[string]interface{}{"d": 2, "f": 3}, "e": []interface{}{2}}
+	if got := ApplyMergePatch(doc, patch); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if _, ok := doc["a"]; !ok {
+		t.Error("doc was modified")
+	}
+}
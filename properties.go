@@ -0,0 +1,156 @@
+package goreflect
+
+import (
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/magiconair/properties"
+	"github.com/pkg/errors"
+)
+
+type propertiesCodec struct{}
+
+// Decode reads a Java properties file. Dotted keys become nested maps, so
+// "a.b.c=1" decodes to {"a": {"b": {"c": "1"}}}, and maps whose keys are
+// exactly 0..n-1 become slices. A key that is both a value and a parent,
+// like "a=1" next to "a.b=2", keeps its dotted form in the nearest map.
+func (propertiesCodec) Decode(r io.Reader, c map[string]interface{}) error {
+	b, err := readAll(r)
+	if err != nil {
+		return err
+	}
+	p := properties.NewProperties()
+	p.DisableExpansion = true
+	if err := p.Load(b, properties.UTF8); err != nil {
+		return errors.WithStack(err)
+	}
+
+	keys := p.Keys()
+	sort.Strings(keys)
+	for _, key := range keys {
+		val, _ := p.Get(key)
+		unflattenInto(c, strings.Split(key, "."), val)
+	}
+	for k, v := range c {
+		c[k] = mapsToSlices(v)
+	}
+	return nil
+}
+
+// Encode writes c as a Java properties file, flattening nested maps and
+// slices into dotted keys in sorted order.
+func (propertiesCodec) Encode(w io.Writer, c map[string]interface{}) error {
+	flat := make(map[string]string)
+	if err := flattenInto(flat, "", stringKeys(c)); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(flat))
+	for k := range flat {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	p := properties.NewProperties()
+	p.DisableExpansion = true
+	for _, k := range keys {
+		if _, _, err := p.Set(k, flat[k]); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	_, err := p.Write(w, properties.UTF8)
+	return errors.WithStack(err)
+}
+
+func (propertiesCodec) Extensions() []string { return []string{".properties"} }
+func (propertiesCodec) MimeType() string     { return "text/x-java-properties" }
+
+var propertiesLineRe = regexp.MustCompile(`^[\w.\-]+\s*[=:]`)
+
+func (propertiesCodec) Sniff(head []byte) float64 {
+	line, _ := significant(head)
+	if line != nil && line[0] != '!' && propertiesLineRe.Match(line) {
+		return 0.3
+	}
+	return 0
+}
+
+func unflattenInto(m map[string]interface{}, path []string, val string) {
+	for i, k := range path[:len(path)-1] {
+		next, ok := m[k]
+		if !ok {
+			nm := make(map[string]interface{})
+			m[k] = nm
+			m = nm
+			continue
+		}
+		nm, ok := next.(map[string]interface{})
+		if !ok {
+			m[strings.Join(path[i:], ".")] = val
+			return
+		}
+		m = nm
+	}
+	last := path[len(path)-1]
+	if _, ok := m[last].(map[string]interface{}); ok {
+		m[strings.Join(path, ".")] = val
+		return
+	}
+	m[last] = val
+}
+
+func mapsToSlices(v interface{}) interface{} {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return v
+	}
+	for k, val := range m {
+		m[k] = mapsToSlices(val)
+	}
+	s := make([]interface{}, len(m))
+	for k, val := range m {
+		i, err := strconv.Atoi(k)
+		if err != nil || i < 0 || i >= len(m) || strconv.Itoa(i) != k {
+			return m
+		}
+		s[i] = val
+	}
+	if len(s) == 0 {
+		return m
+	}
+	return s
+}
+
+func flattenInto(flat map[string]string, prefix string, v interface{}) error {
+	join := func(k string) string {
+		if prefix == "" {
+			return k
+		}
+		return prefix + "." + k
+	}
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, val := range v {
+			if err := flattenInto(flat, join(k), val); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for i, val := range v {
+			if err := flattenInto(flat, join(strconv.Itoa(i)), val); err != nil {
+				return err
+			}
+		}
+	case nil:
+		flat[prefix] = ""
+	default:
+		s, err := ToStringE(v)
+		if err != nil {
+			return errors.Wrapf(err, "property %q", prefix)
+		}
+		flat[prefix] = s
+	}
+	return nil
+}
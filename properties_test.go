@@ -0,0 +1,109 @@
+package goreflect
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPropertiesCodec(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want map[string]interface{}
+		out  string
+	}{
+		{
+			name: "flat",
+			in:   "# comment\nname = web\nport: 80\n",
+			want: map[string]interface{}{"name": "web", "port": "80"},
+			out:  "name = web\nport = 80\n",
+		},
+		{
+			name: "dotted keys nest",
+			in:   "server.host=a\nserver.tls.enabled=true\n",
+			want: map[string]interface{}{
+				"server": map[string]interface{}{
+					"host": "a",
+					"tls":  map[string]interface{}{"enabled": "true"},
+				},
+			},
+			out: "server.host = a\nserver.tls.enabled = true\n",
+		},
+		{
+			name: "indexes become slices",
+			in:   "tags.0=a\ntags.1=b\nports.1=80\n",
+			want: map[string]interface{}{
+				"tags":  []interface{}{"a", "b"},
+				"ports": map[string]interface{}{"1": "80"},
+			},
+			out: "ports.1 = 80\ntags.0 = a\ntags.1 = b\n",
+		},
+		{
+			name: "value and parent",
+			in:   "a=1\na.b=2\n",
+			want: map[string]interface{}{"a": "1", "a.b": "2"},
+			out:  "a = 1\na.b = 2\n",
+		},
+		{
+			name: "no expansion",
+			in:   "home=${HOME}\n",
+			want: map[string]interface{}{"home": "${HOME}"},
+			out:  "home = ${HOME}\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := make(map[string]interface{})
+			if err := (propertiesCodec{}).Decode(strings.NewReader(tt.in), c); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(c, tt.want) {
+				t.Fatalf("got %#v, want %#v", c, tt.want)
+			}
+
+			var buf bytes.Buffer
+			if err := (propertiesCodec{}).Encode(&buf, c); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tt.out {
+				t.Errorf("encoded %q, want %q", buf.String(), tt.out)
+			}
+			again := make(map[string]interface{})
+			if err := (propertiesCodec{}).Decode(&buf, again); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(again, tt.want) {
+				t.Errorf("round trip got %#v, want %#v", again, tt.want)
+			}
+		})
+	}
+}
+
+func TestPropertiesTOMLRoundTrip(t *testing.T) {
+	const in = "[server]\nhost = \"a\"\nports = [\"80\", \"443\"]\n"
+	a := make(map[string]interface{})
+	if err := MarshalReader(strings.NewReader(in), TOML, a); err != nil {
+		t.Fatal(err)
+	}
+	var props bytes.Buffer
+	if err := MarshalWriter(&props, a, PROPERTIES); err != nil {
+		t.Fatal(err)
+	}
+	b := make(map[string]interface{})
+	if err := MarshalReader(&props, PROPERTIES, b); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := MarshalWriter(&out, b, TOML); err != nil {
+		t.Fatal(err)
+	}
+	c := make(map[string]interface{})
+	if err := MarshalReader(&out, TOML, c); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(a, c) {
+		t.Errorf("got %v, want %v", c, a)
+	}
+}
@@ -0,0 +1,1561 @@
+package goreflect
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ProtoCodec decodes and encodes protocol buffer messages. Decoding needs
+// the message descriptor, loaded by NewProtoCodec, to tell repeated fields
+// apart; the zero value, which is registered for PROTO, only encodes the
+// text format unless Schemaless is set. Pass a descriptor for one call with
+// DecodeOptions.Proto and WriteOptions.Proto. A codec with a descriptor can
+// also handle the binary wire format.
+//
+// Decoded values use the proto field names as keys, enums as their value
+// names and bytes as base64 strings, mirroring the proto3 JSON mapping.
+type ProtoCodec struct {
+	// Binary selects the wire format instead of the text format.
+	Binary bool
+	// Schemaless lets a codec without a descriptor decode the text format,
+	// inferring field types from their literals. Fields seen more than once
+	// become lists, so whether a repeated field decodes as a list depends
+	// on the data.
+	Schemaless bool
+
+	msg *protoMessage
+}
+
+// NewProtoCodec loads a FileDescriptorSet, as written by
+// `protoc --include_imports --descriptor_set_out`, and returns a codec for
+// the fully qualified message name, e.g. "acme.config.v1.Service".
+func NewProtoCodec(descriptorSetFile, message string) (*ProtoCodec, error) {
+	b, err := ioutil.ReadFile(descriptorSetFile)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	set, err := parseDescriptorSet(b)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading descriptor set %s", descriptorSetFile)
+	}
+	msg, ok := set.messages["."+strings.TrimPrefix(message, ".")]
+	if !ok {
+		return nil, errors.Errorf("message %q not found in descriptor set %s", message, descriptorSetFile)
+	}
+	return &ProtoCodec{msg: msg}, nil
+}
+
+// ProtoOptions configures the PROTO codec for one call of
+// MarshalReaderWith or MarshalWriterWith.
+type ProtoOptions struct {
+	// DescriptorSetFile and Message name the message, as for
+	// NewProtoCodec. Without them only Schemaless text decoding works.
+	DescriptorSetFile string
+	Message           string
+	// Binary and Schemaless set the fields of the same name of the codec.
+	Binary     bool
+	Schemaless bool
+}
+
+func (o *ProtoOptions) codec() (Codec, error) {
+	p := &ProtoCodec{}
+	if o.DescriptorSetFile != "" {
+		var err error
+		if p, err = NewProtoCodec(o.DescriptorSetFile, o.Message); err != nil {
+			return nil, err
+		}
+	}
+	p.Binary, p.Schemaless = o.Binary, o.Schemaless
+	return p, nil
+}
+
+func (p *ProtoCodec) Decode(r io.Reader, c map[string]interface{}) error {
+	b, err := readAll(r)
+	if err != nil {
+		return err
+	}
+
+	var m map[string]interface{}
+	if p.Binary {
+		if p.msg == nil {
+			return errors.New("protobuf wire format requires a message descriptor, see NewProtoCodec")
+		}
+		m, err = p.msg.decodeWire(b)
+	} else {
+		if p.msg == nil && !p.Schemaless {
+			return errors.New("decoding protobuf text format requires a message descriptor, see NewProtoCodec, or Schemaless")
+		}
+		var fields []protoTextField
+		fields, err = parseProtoText(b)
+		if err == nil {
+			m, err = p.msg.fromText(fields)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	for k, v := range m {
+		c[k] = v
+	}
+	return nil
+}
+
+func (p *ProtoCodec) Encode(w io.Writer, c map[string]interface{}) error {
+	c = stringKeys(c).(map[string]interface{})
+	if p.Binary {
+		if p.msg == nil {
+			return errors.New("protobuf wire format requires a message descriptor, see NewProtoCodec")
+		}
+		b, err := p.msg.encodeWire(nil, c)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(b)
+		return errors.WithStack(err)
+	}
+
+	var buf bytes.Buffer
+	if err := p.msg.writeText(&buf, 0, c); err != nil {
+		return err
+	}
+	_, err := buf.WriteTo(w)
+	return errors.WithStack(err)
+}
+
+func (p *ProtoCodec) Extensions() []string {
+	if p.Binary {
+		return []string{".pb", ".binpb"}
+	}
+	return []string{".textproto", ".txtpb", ".pbtxt", ".prototxt"}
+}
+
+func (p *ProtoCodec) MimeType() string {
+	if p.Binary {
+		return "application/x-protobuf"
+	}
+	return "text/x-protobuf"
+}
+
+func init() {
+	RegisterCodec(PROTO, "PROTO", &ProtoCodec{})
+}
+
+// Field types and labels from google/protobuf/descriptor.proto.
+const (
+	protoDouble   = 1
+	protoFloat    = 2
+	protoInt64    = 3
+	protoUint64   = 4
+	protoInt32    = 5
+	protoFixed64  = 6
+	protoFixed32  = 7
+	protoBool     = 8
+	protoString   = 9
+	protoGroup    = 10
+	protoMessageT = 11
+	protoBytes    = 12
+	protoUint32   = 13
+	protoEnum     = 14
+	protoSfixed32 = 15
+	protoSfixed64 = 16
+	protoSint32   = 17
+	protoSint64   = 18
+
+	protoRepeated = 3
+)
+
+// Wire types.
+const (
+	wireVarint     = 0
+	wireFixed64    = 1
+	wireBytes      = 2
+	wireStartGroup = 3
+	wireEndGroup   = 4
+	wireFixed32    = 5
+)
+
+type protoDescriptorSet struct {
+	messages map[string]*protoMessage
+	enums    map[string]*protoEnumType
+}
+
+type protoMessage struct {
+	name     string
+	fields   []*protoField
+	byNumber map[int32]*protoField
+	mapEntry bool
+}
+
+type protoField struct {
+	name     string
+	jsonName string
+	number   int32
+	label    int32
+	typ      int32
+	typeName string
+	packed   bool
+	message  *protoMessage
+	enum     *protoEnumType
+}
+
+type protoEnumType struct {
+	byNumber map[int32]string
+	byName   map[string]int32
+}
+
+func (f *protoField) repeated() bool { return f.label == protoRepeated }
+
+func (f *protoField) isMap() bool {
+	return f.repeated() && f.message != nil && f.message.mapEntry
+}
+
+func (f *protoField) packable() bool {
+	switch f.typ {
+	case protoString, protoBytes, protoMessageT, protoGroup:
+		return false
+	}
+	return f.repeated()
+}
+
+func (m *protoMessage) lookup(key string) *protoField {
+	if m == nil {
+		return nil
+	}
+	for _, f := range m.fields {
+		if strings.EqualFold(f.name, key) || strings.EqualFold(f.jsonName, key) {
+			return f
+		}
+	}
+	return nil
+}
+
+func parseDescriptorSet(b []byte) (*protoDescriptorSet, error) {
+	set := &protoDescriptorSet{
+		messages: make(map[string]*protoMessage),
+		enums:    make(map[string]*protoEnumType),
+	}
+	var fields []*protoField
+	err := walkWire(b, func(num int32, wt int, _ uint64, data []byte) error {
+		if num != 1 || wt != wireBytes {
+			return nil
+		}
+		fs, err := set.parseFile(data)
+		fields = append(fields, fs...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range fields {
+		switch f.typ {
+		case protoMessageT, protoGroup:
+			if f.message = set.messages[f.typeName]; f.message == nil {
+				return nil, errors.Errorf("field %s refers to unknown message %s", f.name, f.typeName)
+			}
+		case protoEnum:
+			if f.enum = set.enums[f.typeName]; f.enum == nil {
+				return nil, errors.Errorf("field %s refers to unknown enum %s", f.name, f.typeName)
+			}
+		}
+	}
+	return set, nil
+}
+
+func (set *protoDescriptorSet) parseFile(b []byte) ([]*protoField, error) {
+	var pkg, syntax string
+	var msgs, enums [][]byte
+	err := walkWire(b, func(num int32, wt int, _ uint64, data []byte) error {
+		switch num {
+		case 2:
+			pkg = string(data)
+		case 4:
+			msgs = append(msgs, data)
+		case 5:
+			enums = append(enums, data)
+		case 12:
+			syntax = string(data)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	scope := "."
+	if pkg != "" {
+		scope = "." + pkg + "."
+	}
+	for _, e := range enums {
+		if err := set.parseEnum(scope, e); err != nil {
+			return nil, err
+		}
+	}
+	var fields []*protoField
+	for _, m := range msgs {
+		fs, err := set.parseMessage(scope, m, syntax == "proto3")
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, fs...)
+	}
+	return fields, nil
+}
+
+func (set *protoDescriptorSet) parseMessage(scope string, b []byte, proto3 bool) ([]*protoField, error) {
+	msg := &protoMessage{byNumber: make(map[int32]*protoField)}
+	var nested, enums [][]byte
+	err := walkWire(b, func(num int32, wt int, _ uint64, data []byte) error {
+		switch num {
+		case 1:
+			msg.name = scope + string(data)
+		case 2:
+			f, err := parseFieldDescriptor(data, proto3)
+			if err != nil {
+				return err
+			}
+			msg.fields = append(msg.fields, f)
+			msg.byNumber[f.number] = f
+		case 3:
+			nested = append(nested, data)
+		case 4:
+			enums = append(enums, data)
+		case 7:
+			return walkWire(data, func(num int32, _ int, v uint64, _ []byte) error {
+				if num == 7 {
+					msg.mapEntry = v != 0
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	set.messages[msg.name] = msg
+
+	fields := append([]*protoField(nil), msg.fields...)
+	for _, e := range enums {
+		if err := set.parseEnum(msg.name+".", e); err != nil {
+			return nil, err
+		}
+	}
+	for _, n := range nested {
+		fs, err := set.parseMessage(msg.name+".", n, proto3)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, fs...)
+	}
+	return fields, nil
+}
+
+func parseFieldDescriptor(b []byte, proto3 bool) (*protoField, error) {
+	f := &protoField{}
+	packed := -1
+	err := walkWire(b, func(num int32, wt int, v uint64, data []byte) error {
+		switch num {
+		case 1:
+			f.name = string(data)
+		case 3:
+			f.number = int32(v)
+		case 4:
+			f.label = int32(v)
+		case 5:
+			f.typ = int32(v)
+		case 6:
+			f.typeName = string(data)
+		case 8:
+			return walkWire(data, func(num int32, _ int, v uint64, _ []byte) error {
+				if num == 2 {
+					packed = int(v)
+				}
+				return nil
+			})
+		case 10:
+			f.jsonName = string(data)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	f.packed = f.packable() && (packed == 1 || (proto3 && packed != 0))
+	return f, nil
+}
+
+func (set *protoDescriptorSet) parseEnum(scope string, b []byte) error {
+	e := &protoEnumType{byNumber: make(map[int32]string), byName: make(map[string]int32)}
+	var name string
+	err := walkWire(b, func(num int32, wt int, _ uint64, data []byte) error {
+		switch num {
+		case 1:
+			name = string(data)
+		case 2:
+			var vname string
+			var vnum int32
+			err := walkWire(data, func(num int32, _ int, v uint64, data []byte) error {
+				switch num {
+				case 1:
+					vname = string(data)
+				case 2:
+					vnum = int32(v)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if _, ok := e.byNumber[vnum]; !ok {
+				e.byNumber[vnum] = vname
+			}
+			e.byName[vname] = vnum
+		}
+		return nil
+	})
+	set.enums[scope+name] = e
+	return err
+}
+
+// walkWire calls fn for every field of an encoded message. For
+// length-delimited fields and groups data holds the payload, otherwise v
+// holds the raw value.
+func walkWire(b []byte, fn func(num int32, wt int, v uint64, data []byte) error) error {
+	for len(b) > 0 {
+		tag, n := consumeVarint(b)
+		if n <= 0 {
+			return errors.New("protobuf: truncated tag")
+		}
+		b = b[n:]
+		num, wt := int32(tag>>3), int(tag&7)
+		if num <= 0 {
+			return errors.Errorf("protobuf: invalid field number %d", num)
+		}
+
+		var v uint64
+		var data []byte
+		switch wt {
+		case wireVarint:
+			v, n = consumeVarint(b)
+			if n <= 0 {
+				return errors.New("protobuf: truncated varint")
+			}
+		case wireFixed64:
+			if len(b) < 8 {
+				return errors.New("protobuf: truncated fixed64")
+			}
+			v, n = uint64(b[0])|uint64(b[1])<<8|uint64(b[2])<<16|uint64(b[3])<<24|
+				uint64(b[4])<<32|uint64(b[5])<<40|uint64(b[6])<<48|uint64(b[7])<<56, 8
+		case wireFixed32:
+			if len(b) < 4 {
+				return errors.New("protobuf: truncated fixed32")
+			}
+			v, n = uint64(b[0])|uint64(b[1])<<8|uint64(b[2])<<16|uint64(b[3])<<24, 4
+		case wireBytes:
+			l, ln := consumeVarint(b)
+			if ln <= 0 || l > uint64(len(b)-ln) {
+				return errors.New("protobuf: truncated length-delimited field")
+			}
+			data, n = b[ln:ln+int(l)], ln+int(l)
+		case wireStartGroup:
+			l, err := groupLen(b, num)
+			if err != nil {
+				return err
+			}
+			data, n = b[:l], l
+			// skip the end group tag
+			_, tn := consumeVarint(b[l:])
+			n += tn
+		default:
+			return errors.Errorf("protobuf: unexpected wire type %d", wt)
+		}
+		b = b[n:]
+
+		if err := fn(num, wt, v, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// groupLen returns the length of the group body at the start of b, up to
+// but excluding the end group tag of field num.
+func groupLen(b []byte, num int32) (int, error) {
+	off := 0
+	for off < len(b) {
+		tag, n := consumeVarint(b[off:])
+		if n <= 0 {
+			return 0, errors.New("protobuf: truncated group")
+		}
+		if int32(tag>>3) == num && tag&7 == wireEndGroup {
+			return off, nil
+		}
+		rest := b[off+n:]
+		skip := 0
+		switch tag & 7 {
+		case wireVarint:
+			if _, skip = consumeVarint(rest); skip <= 0 {
+				return 0, errors.New("protobuf: truncated group")
+			}
+		case wireFixed64:
+			skip = 8
+		case wireFixed32:
+			skip = 4
+		case wireBytes:
+			l, ln := consumeVarint(rest)
+			if ln <= 0 || l > uint64(len(rest)-ln) {
+				return 0, errors.New("protobuf: truncated group")
+			}
+			skip = ln + int(l)
+		case wireStartGroup:
+			l, err := groupLen(rest, int32(tag>>3))
+			if err != nil {
+				return 0, err
+			}
+			_, en := consumeVarint(rest[l:])
+			skip = l + en
+		default:
+			return 0, errors.Errorf("protobuf: unexpected wire type %d", tag&7)
+		}
+		off += n + skip
+		if off > len(b) {
+			return 0, errors.New("protobuf: truncated group")
+		}
+	}
+	return 0, errors.New("protobuf: unterminated group")
+}
+
+func consumeVarint(b []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < len(b) && i < 10; i++ {
+		v |= uint64(b[i]&0x7f) << (7 * uint(i))
+		if b[i] < 0x80 {
+			return v, i + 1
+		}
+	}
+	return 0, -1
+}
+
+func appendVarint(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+func appendFixed32(b []byte, v uint32) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+func appendFixed64(b []byte, v uint64) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24),
+		byte(v>>32), byte(v>>40), byte(v>>48), byte(v>>56))
+}
+
+func (m *protoMessage) decodeWire(b []byte) (map[string]interface{}, error) {
+	out := make(map[string]interface{})
+	err := walkWire(b, func(num int32, wt int, v uint64, data []byte) error {
+		f, ok := m.byNumber[num]
+		if !ok {
+			// unknown fields are dropped
+			return nil
+		}
+
+		if f.isMap() {
+			entry, err := f.message.decodeWire(data)
+			if err != nil {
+				return err
+			}
+			mv, _ := out[f.name].(map[string]interface{})
+			if mv == nil {
+				mv = make(map[string]interface{})
+				out[f.name] = mv
+			}
+			key := entry["key"]
+			if key == nil {
+				key = f.message.zeroOf("key")
+			}
+			val, ok := entry["value"]
+			if !ok {
+				val = f.message.zeroOf("value")
+			}
+			mv[StrVal(key)] = val
+			return nil
+		}
+
+		var vals []interface{}
+		if wt == wireBytes && f.packable() {
+			var err error
+			if vals, err = f.decodePacked(data); err != nil {
+				return err
+			}
+		} else {
+			val, err := f.decodeWireValue(wt, v, data)
+			if err != nil {
+				return err
+			}
+			vals = []interface{}{val}
+		}
+
+		if f.repeated() {
+			list, _ := out[f.name].([]interface{})
+			out[f.name] = append(list, vals...)
+		} else {
+			out[f.name] = vals[len(vals)-1]
+		}
+		return nil
+	})
+	return out, err
+}
+
+// zeroOf returns the default value of a map entry's key or value field.
+func (m *protoMessage) zeroOf(name string) interface{} {
+	f := m.lookup(name)
+	if f == nil {
+		return nil
+	}
+	switch f.typ {
+	case protoString, protoBytes:
+		return ""
+	case protoBool:
+		return false
+	case protoDouble, protoFloat:
+		return float64(0)
+	case protoUint32, protoUint64, protoFixed32, protoFixed64:
+		return uint64(0)
+	case protoEnum:
+		return f.enumName(0)
+	case protoMessageT, protoGroup:
+		return make(map[string]interface{})
+	}
+	return int64(0)
+}
+
+func (f *protoField) wireType() int {
+	switch f.typ {
+	case protoDouble, protoFixed64, protoSfixed64:
+		return wireFixed64
+	case protoFloat, protoFixed32, protoSfixed32:
+		return wireFixed32
+	case protoString, protoBytes, protoMessageT:
+		return wireBytes
+	case protoGroup:
+		return wireStartGroup
+	}
+	return wireVarint
+}
+
+func (f *protoField) decodePacked(b []byte) ([]interface{}, error) {
+	wt := f.wireType()
+	var vals []interface{}
+	for len(b) > 0 {
+		var v uint64
+		var n int
+		switch wt {
+		case wireVarint:
+			v, n = consumeVarint(b)
+		case wireFixed32:
+			if len(b) >= 4 {
+				v, n = uint64(b[0])|uint64(b[1])<<8|uint64(b[2])<<16|uint64(b[3])<<24, 4
+			}
+		case wireFixed64:
+			if len(b) >= 8 {
+				v, n = uint64(b[0])|uint64(b[1])<<8|uint64(b[2])<<16|uint64(b[3])<<24|
+					uint64(b[4])<<32|uint64(b[5])<<40|uint64(b[6])<<48|uint64(b[7])<<56, 8
+			}
+		}
+		if n <= 0 {
+			return nil, errors.Errorf("protobuf: truncated packed field %s", f.name)
+		}
+		b = b[n:]
+		val, err := f.decodeWireValue(wt, v, nil)
+		if err != nil {
+			return nil, err
+		}
+		vals = append(vals, val)
+	}
+	return vals, nil
+}
+
+func (f *protoField) decodeWireValue(wt int, v uint64, data []byte) (interface{}, error) {
+	if wt != f.wireType() {
+		return nil, errors.Errorf("protobuf: field %s has wire type %d, expected %d", f.name, wt, f.wireType())
+	}
+	switch f.typ {
+	case protoDouble:
+		return math.Float64frombits(v), nil
+	case protoFloat:
+		return float64(math.Float32frombits(uint32(v))), nil
+	case protoInt64, protoSfixed64:
+		return int64(v), nil
+	case protoInt32, protoSfixed32:
+		return int64(int32(v)), nil
+	case protoUint64, protoFixed64:
+		return v, nil
+	case protoUint32, protoFixed32:
+		return uint64(uint32(v)), nil
+	case protoSint32:
+		return int64(int32(uint32(v)>>1) ^ -int32(v&1)), nil
+	case protoSint64:
+		return int64(v>>1) ^ -int64(v&1), nil
+	case protoBool:
+		return v != 0, nil
+	case protoEnum:
+		return f.enumName(int32(v)), nil
+	case protoString:
+		return string(data), nil
+	case protoBytes:
+		return base64.StdEncoding.EncodeToString(data), nil
+	case protoMessageT, protoGroup:
+		return f.message.decodeWire(data)
+	}
+	return nil, errors.Errorf("protobuf: field %s has unknown type %d", f.name, f.typ)
+}
+
+func (f *protoField) enumName(n int32) interface{} {
+	if name, ok := f.enum.byNumber[n]; ok {
+		return name
+	}
+	return int64(n)
+}
+
+func (f *protoField) enumNumber(v interface{}) (int32, error) {
+	if s, ok := v.(string); ok {
+		if n, ok := f.enum.byName[s]; ok {
+			return n, nil
+		}
+	}
+	n, err := ToInt32E(v)
+	if err != nil {
+		return 0, errors.Errorf("protobuf: %#v is not a value of enum field %s", v, f.name)
+	}
+	return n, nil
+}
+
+// sortedFields returns the fields of m present in v, ordered by field number.
+func (m *protoMessage) sortedFields(v map[string]interface{}) ([]*protoField, []interface{}, error) {
+	type pair struct {
+		f *protoField
+		v interface{}
+	}
+	pairs := make([]pair, 0, len(v))
+	for k, val := range v {
+		f := m.lookup(k)
+		if f == nil {
+			return nil, nil, errors.Errorf("protobuf: unknown field %q in message %s", k, m.name)
+		}
+		if val != nil {
+			pairs = append(pairs, pair{f, val})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].f.number < pairs[j].f.number })
+
+	fields := make([]*protoField, len(pairs))
+	vals := make([]interface{}, len(pairs))
+	for i, p := range pairs {
+		fields[i], vals[i] = p.f, p.v
+	}
+	return fields, vals, nil
+}
+
+func (m *protoMessage) encodeWire(b []byte, v map[string]interface{}) ([]byte, error) {
+	fields, vals, err := m.sortedFields(v)
+	if err != nil {
+		return nil, err
+	}
+	for i, f := range fields {
+		val := vals[i]
+		switch {
+		case f.isMap():
+			entries, err := ToStringMapE(val)
+			if err != nil {
+				return nil, errors.Wrapf(err, "protobuf: field %s", f.name)
+			}
+			for _, k := range sortedKeys(entries) {
+				entry, err := f.message.encodeWire(nil, map[string]interface{}{"key": k, "value": entries[k]})
+				if err != nil {
+					return nil, err
+				}
+				b = appendVarint(b, uint64(f.number)<<3|wireBytes)
+				b = appendVarint(b, uint64(len(entry)))
+				b = append(b, entry...)
+			}
+
+		case f.repeated():
+			list, err := ToSliceE(val)
+			if err != nil {
+				list = []interface{}{val}
+			}
+			if f.packed {
+				var packed []byte
+				for _, item := range list {
+					if packed, err = f.appendWireValue(packed, item); err != nil {
+						return nil, err
+					}
+				}
+				b = appendVarint(b, uint64(f.number)<<3|wireBytes)
+				b = appendVarint(b, uint64(len(packed)))
+				b = append(b, packed...)
+				continue
+			}
+			for _, item := range list {
+				if b, err = f.appendWireField(b, item); err != nil {
+					return nil, err
+				}
+			}
+
+		default:
+			if b, err = f.appendWireField(b, val); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return b, nil
+}
+
+func (f *protoField) appendWireField(b []byte, v interface{}) ([]byte, error) {
+	wt := f.wireType()
+	b = appendVarint(b, uint64(f.number)<<3|uint64(wt))
+	b, err := f.appendWireValue(b, v)
+	if err != nil {
+		return nil, err
+	}
+	if wt == wireStartGroup {
+		b = appendVarint(b, uint64(f.number)<<3|wireEndGroup)
+	}
+	return b, nil
+}
+
+func (f *protoField) appendWireValue(b []byte, v interface{}) ([]byte, error) {
+	wrap := func(err error) error {
+		return errors.Wrapf(err, "protobuf: field %s", f.name)
+	}
+	switch f.typ {
+	case protoDouble:
+		n, err := ToFloat64E(v)
+		if err != nil {
+			return nil, wrap(err)
+		}
+		return appendFixed64(b, math.Float64bits(n)), nil
+	case protoFloat:
+		n, err := ToFloat32E(v)
+		if err != nil {
+			return nil, wrap(err)
+		}
+		return appendFixed32(b, math.Float32bits(n)), nil
+	case protoInt64, protoInt32:
+		n, err := ToInt64E(v)
+		if err != nil {
+			return nil, wrap(err)
+		}
+		return appendVarint(b, uint64(n)), nil
+	case protoSfixed64:
+		n, err := ToInt64E(v)
+		if err != nil {
+			return nil, wrap(err)
+		}
+		return appendFixed64(b, uint64(n)), nil
+	case protoSfixed32:
+		n, err := ToInt32E(v)
+		if err != nil {
+			return nil, wrap(err)
+		}
+		return appendFixed32(b, uint32(n)), nil
+	case protoUint64, protoUint32:
+		n, err := ToUint64E(v)
+		if err != nil {
+			return nil, wrap(err)
+		}
+		return appendVarint(b, n), nil
+	case protoFixed64:
+		n, err := ToUint64E(v)
+		if err != nil {
+			return nil, wrap(err)
+		}
+		return appendFixed64(b, n), nil
+	case protoFixed32:
+		n, err := ToUint32E(v)
+		if err != nil {
+			return nil, wrap(err)
+		}
+		return appendFixed32(b, n), nil
+	case protoSint32, protoSint64:
+		n, err := ToInt64E(v)
+		if err != nil {
+			return nil, wrap(err)
+		}
+		return appendVarint(b, uint64(n<<1)^uint64(n>>63)), nil
+	case protoBool:
+		t, err := ToBoolE(v)
+		if err != nil {
+			return nil, wrap(err)
+		}
+		if t {
+			return appendVarint(b, 1), nil
+		}
+		return appendVarint(b, 0), nil
+	case protoEnum:
+		n, err := f.enumNumber(v)
+		if err != nil {
+			return nil, err
+		}
+		return appendVarint(b, uint64(n)), nil
+	case protoString:
+		s, err := ToStringE(v)
+		if err != nil {
+			return nil, wrap(err)
+		}
+		b = appendVarint(b, uint64(len(s)))
+		return append(b, s...), nil
+	case protoBytes:
+		data, err := protoBytesValue(v)
+		if err != nil {
+			return nil, wrap(err)
+		}
+		b = appendVarint(b, uint64(len(data)))
+		return append(b, data...), nil
+	case protoMessageT, protoGroup:
+		m, err := ToStringMapE(v)
+		if err != nil {
+			return nil, wrap(err)
+		}
+		nested, err := f.message.encodeWire(nil, m)
+		if err != nil {
+			return nil, err
+		}
+		if f.typ == protoMessageT {
+			b = appendVarint(b, uint64(len(nested)))
+		}
+		return append(b, nested...), nil
+	}
+	return nil, errors.Errorf("protobuf: field %s has unknown type %d", f.name, f.typ)
+}
+
+func protoBytesValue(v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		return base64.StdEncoding.DecodeString(v)
+	}
+	return nil, errors.Errorf("unable to cast %#v of type %T to bytes", v, v)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Text format
+
+type protoTokenKind int
+
+const (
+	protoTokEOF protoTokenKind = iota
+	protoTokIdent
+	protoTokString
+	protoTokNumber
+	protoTokPunct
+)
+
+type protoToken struct {
+	kind protoTokenKind
+	text string
+	line int
+}
+
+// protoTextField is a field of a parsed text format message. Exactly one of
+// tok and fields is set.
+type protoTextField struct {
+	name   string
+	line   int
+	tok    protoToken
+	fields []protoTextField
+	nested bool
+}
+
+type protoTextParser struct {
+	src  []byte
+	pos  int
+	line int
+	tok  protoToken
+}
+
+func parseProtoText(src []byte) ([]protoTextField, error) {
+	p := &protoTextParser{src: src, line: 1}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	fields, err := p.fields("")
+	if err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+func (p *protoTextParser) errorf(format string, args ...interface{}) error {
+	return errors.Errorf("protobuf text: line %d: %s", p.tok.line, fmt.Sprintf(format, args...))
+}
+
+func (p *protoTextParser) next() error {
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch {
+		case c == '\n':
+			p.line++
+			p.pos++
+		case c == ' ' || c == '\t' || c == '\r':
+			p.pos++
+		case c == '#':
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+		default:
+			goto token
+		}
+	}
+	p.tok = protoToken{kind: protoTokEOF, line: p.line}
+	return nil
+
+token:
+	start := p.pos
+	c := p.src[p.pos]
+	switch {
+	case c == '"' || c == '\'':
+		p.pos++
+		for p.pos < len(p.src) && p.src[p.pos] != c {
+			if p.src[p.pos] == '\n' {
+				return errors.Errorf("protobuf text: line %d: unterminated string", p.line)
+			}
+			if p.src[p.pos] == '\\' {
+				p.pos++
+			}
+			p.pos++
+		}
+		if p.pos >= len(p.src) {
+			return errors.Errorf("protobuf text: line %d: unterminated string", p.line)
+		}
+		p.pos++
+		s, err := unquoteProtoString(string(p.src[start:p.pos]))
+		if err != nil {
+			return errors.Errorf("protobuf text: line %d: %v", p.line, err)
+		}
+		p.tok = protoToken{kind: protoTokString, text: s, line: p.line}
+	case c == '-' || c == '.' || (c >= '0' && c <= '9'):
+		p.pos++
+		for p.pos < len(p.src) {
+			d, prev := p.src[p.pos], p.src[p.pos-1]
+			if !isProtoIdentChar(d) && d != '.' && !((d == '-' || d == '+') && (prev == 'e' || prev == 'E')) {
+				break
+			}
+			p.pos++
+		}
+		p.tok = protoToken{kind: protoTokNumber, text: string(p.src[start:p.pos]), line: p.line}
+	case isProtoIdentChar(c):
+		for p.pos < len(p.src) && (isProtoIdentChar(p.src[p.pos]) || p.src[p.pos] == '.') {
+			p.pos++
+		}
+		p.tok = protoToken{kind: protoTokIdent, text: string(p.src[start:p.pos]), line: p.line}
+	default:
+		p.pos++
+		p.tok = protoToken{kind: protoTokPunct, text: string(c), line: p.line}
+	}
+	return nil
+}
+
+func isProtoIdentChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+func (p *protoTextParser) isPunct(s string) bool {
+	return p.tok.kind == protoTokPunct && p.tok.text == s
+}
+
+func (p *protoTextParser) fields(end string) ([]protoTextField, error) {
+	var fields []protoTextField
+	for {
+		if end == "" && p.tok.kind == protoTokEOF {
+			return fields, nil
+		}
+		if end != "" && p.isPunct(end) {
+			return fields, p.next()
+		}
+		if p.tok.kind != protoTokIdent {
+			if p.isPunct("[") {
+				return nil, p.errorf("extension and Any fields are not supported")
+			}
+			return nil, p.errorf("expected field name, got %q", p.tok.text)
+		}
+		name, line := p.tok.text, p.tok.line
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+
+		colon := p.isPunct(":")
+		if colon {
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		}
+
+		if p.isPunct("[") {
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			for !p.isPunct("]") {
+				f, err := p.value(name, line, colon)
+				if err != nil {
+					return nil, err
+				}
+				fields = append(fields, f)
+				if p.isPunct(",") {
+					if err := p.next(); err != nil {
+						return nil, err
+					}
+				} else if !p.isPunct("]") {
+					return nil, p.errorf("expected `,' or `]' in list, got %q", p.tok.text)
+				}
+			}
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		} else {
+			f, err := p.value(name, line, colon)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, f)
+		}
+
+		if p.isPunct(",") || p.isPunct(";") {
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+func (p *protoTextParser) value(name string, line int, colon bool) (protoTextField, error) {
+	f := protoTextField{name: name, line: line}
+	if p.isPunct("{") || p.isPunct("<") {
+		end := "}"
+		if p.tok.text == "<" {
+			end = ">"
+		}
+		if err := p.next(); err != nil {
+			return f, err
+		}
+		nested, err := p.fields(end)
+		f.fields, f.nested = nested, true
+		return f, err
+	}
+	if !colon {
+		return f, p.errorf("expected `:' after field %s", name)
+	}
+
+	switch p.tok.kind {
+	case protoTokString:
+		// adjacent string literals are concatenated
+		f.tok = p.tok
+		if err := p.next(); err != nil {
+			return f, err
+		}
+		for p.tok.kind == protoTokString {
+			f.tok.text += p.tok.text
+			if err := p.next(); err != nil {
+				return f, err
+			}
+		}
+		return f, nil
+	case protoTokNumber, protoTokIdent:
+		f.tok = p.tok
+		return f, p.next()
+	}
+	return f, p.errorf("expected value for field %s, got %q", name, p.tok.text)
+}
+
+func unquoteProtoString(s string) (string, error) {
+	quote := s[0]
+	s = s[1 : len(s)-1]
+	var buf bytes.Buffer
+	for len(s) > 0 {
+		// protobuf allows octal escapes shorter than three digits
+		if len(s) > 1 && s[0] == '\\' && s[1] >= '0' && s[1] <= '7' {
+			n, i := 0, 1
+			for ; i < 4 && i < len(s) && s[i] >= '0' && s[i] <= '7'; i++ {
+				n = n*8 + int(s[i]-'0')
+			}
+			buf.WriteByte(byte(n))
+			s = s[i:]
+			continue
+		}
+		r, multibyte, tail, err := strconv.UnquoteChar(s, quote)
+		if err != nil {
+			return "", err
+		}
+		if r < 0x80 || !multibyte {
+			buf.WriteByte(byte(r))
+		} else {
+			buf.WriteRune(r)
+		}
+		s = tail
+	}
+	return buf.String(), nil
+}
+
+// fromText converts parsed text format fields to a map. m may be nil, in
+// which case value types are inferred.
+func (m *protoMessage) fromText(fields []protoTextField) (map[string]interface{}, error) {
+	out := make(map[string]interface{})
+	if m == nil {
+		counts := make(map[string]int)
+		for _, f := range fields {
+			counts[f.name]++
+		}
+		for _, f := range fields {
+			var val interface{}
+			if f.nested {
+				nested, err := m.fromText(f.fields)
+				if err != nil {
+					return nil, err
+				}
+				val = nested
+			} else {
+				val = inferProtoScalar(f.tok)
+			}
+			if counts[f.name] > 1 {
+				list, _ := out[f.name].([]interface{})
+				out[f.name] = append(list, val)
+			} else {
+				out[f.name] = val
+			}
+		}
+		return out, nil
+	}
+
+	for _, tf := range fields {
+		f := m.lookup(tf.name)
+		if f == nil {
+			return nil, errors.Errorf("protobuf text: line %d: unknown field %q in message %s", tf.line, tf.name, m.name)
+		}
+		val, err := f.fromText(tf)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case f.isMap():
+			entry := val.(map[string]interface{})
+			mv, _ := out[f.name].(map[string]interface{})
+			if mv == nil {
+				mv = make(map[string]interface{})
+				out[f.name] = mv
+			}
+			key := entry["key"]
+			if key == nil {
+				key = f.message.zeroOf("key")
+			}
+			v, ok := entry["value"]
+			if !ok {
+				v = f.message.zeroOf("value")
+			}
+			mv[StrVal(key)] = v
+		case f.repeated():
+			list, _ := out[f.name].([]interface{})
+			out[f.name] = append(list, val)
+		default:
+			out[f.name] = val
+		}
+	}
+	return out, nil
+}
+
+func (f *protoField) fromText(tf protoTextField) (interface{}, error) {
+	fail := func() (interface{}, error) {
+		return nil, errors.Errorf("protobuf text: line %d: invalid value %q for field %s", tf.line, tf.tok.text, f.name)
+	}
+	if f.typ == protoMessageT || f.typ == protoGroup {
+		if !tf.nested {
+			return nil, errors.Errorf("protobuf text: line %d: field %s expects a message", tf.line, f.name)
+		}
+		return f.message.fromText(tf.fields)
+	}
+	if tf.nested {
+		return nil, errors.Errorf("protobuf text: line %d: field %s is not a message", tf.line, f.name)
+	}
+
+	tok := tf.tok
+	switch f.typ {
+	case protoString:
+		if tok.kind != protoTokString {
+			return fail()
+		}
+		return tok.text, nil
+	case protoBytes:
+		if tok.kind != protoTokString {
+			return fail()
+		}
+		return base64.StdEncoding.EncodeToString([]byte(tok.text)), nil
+	case protoBool:
+		switch tok.text {
+		case "true", "True", "t", "1":
+			return true, nil
+		case "false", "False", "f", "0":
+			return false, nil
+		}
+		return fail()
+	case protoEnum:
+		if tok.kind == protoTokIdent {
+			if _, ok := f.enum.byName[tok.text]; ok {
+				return tok.text, nil
+			}
+			return fail()
+		}
+		n, err := strconv.ParseInt(tok.text, 0, 32)
+		if err != nil {
+			return fail()
+		}
+		return f.enumName(int32(n)), nil
+	case protoDouble, protoFloat:
+		n, err := parseProtoFloat(tok.text)
+		if err != nil {
+			return fail()
+		}
+		return n, nil
+	case protoUint32, protoUint64, protoFixed32, protoFixed64:
+		if tok.kind != protoTokNumber {
+			return fail()
+		}
+		n, err := strconv.ParseUint(tok.text, 0, 64)
+		if err != nil {
+			return fail()
+		}
+		return n, nil
+	}
+	if tok.kind != protoTokNumber {
+		return fail()
+	}
+	n, err := strconv.ParseInt(tok.text, 0, 64)
+	if err != nil {
+		return fail()
+	}
+	return n, nil
+}
+
+func parseProtoFloat(s string) (float64, error) {
+	switch strings.ToLower(s) {
+	case "inf", "infinity":
+		return math.Inf(1), nil
+	case "-inf", "-infinity":
+		return math.Inf(-1), nil
+	case "nan":
+		return math.NaN(), nil
+	}
+	return strconv.ParseFloat(strings.TrimRight(s, "fF"), 64)
+}
+
+func inferProtoScalar(tok protoToken) interface{} {
+	switch tok.kind {
+	case protoTokString:
+		return tok.text
+	case protoTokIdent:
+		switch tok.text {
+		case "true", "True":
+			return true
+		case "false", "False":
+			return false
+		}
+		if n, err := parseProtoFloat(tok.text); err == nil {
+			return n
+		}
+		return tok.text
+	}
+	if n, err := strconv.ParseInt(tok.text, 0, 64); err == nil {
+		return n
+	}
+	if n, err := strconv.ParseUint(tok.text, 0, 64); err == nil {
+		return n
+	}
+	if n, err := parseProtoFloat(tok.text); err == nil {
+		return n
+	}
+	return tok.text
+}
+
+// writeText writes v in text format. m may be nil, in which case fields are
+// written in key order with types inferred from the Go values.
+func (m *protoMessage) writeText(buf *bytes.Buffer, depth int, v map[string]interface{}) error {
+	indent := strings.Repeat("  ", depth)
+
+	if m == nil {
+		for _, k := range sortedKeys(v) {
+			list, ok := v[k].([]interface{})
+			if !ok {
+				list = []interface{}{v[k]}
+			}
+			for _, item := range list {
+				if err := writeInferredText(buf, depth, k, item); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	fields, vals, err := m.sortedFields(v)
+	if err != nil {
+		return err
+	}
+	for i, f := range fields {
+		val := vals[i]
+		switch {
+		case f.isMap():
+			entries, err := ToStringMapE(val)
+			if err != nil {
+				return errors.Wrapf(err, "protobuf: field %s", f.name)
+			}
+			for _, k := range sortedKeys(entries) {
+				buf.WriteString(indent + f.name + " {\n")
+				err := f.message.writeText(buf, depth+1, map[string]interface{}{"key": k, "value": entries[k]})
+				if err != nil {
+					return err
+				}
+				buf.WriteString(indent + "}\n")
+			}
+		case f.repeated():
+			list, err := ToSliceE(val)
+			if err != nil {
+				list = []interface{}{val}
+			}
+			for _, item := range list {
+				if err := f.writeText(buf, depth, item); err != nil {
+					return err
+				}
+			}
+		default:
+			if err := f.writeText(buf, depth, val); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (f *protoField) writeText(buf *bytes.Buffer, depth int, v interface{}) error {
+	indent := strings.Repeat("  ", depth)
+	if f.typ == protoMessageT || f.typ == protoGroup {
+		m, err := ToStringMapE(v)
+		if err != nil {
+			return errors.Wrapf(err, "protobuf: field %s", f.name)
+		}
+		buf.WriteString(indent + f.name + " {\n")
+		if err := f.message.writeText(buf, depth+1, m); err != nil {
+			return err
+		}
+		buf.WriteString(indent + "}\n")
+		return nil
+	}
+
+	var s string
+	switch f.typ {
+	case protoString:
+		str, err := ToStringE(v)
+		if err != nil {
+			return errors.Wrapf(err, "protobuf: field %s", f.name)
+		}
+		s = strconv.Quote(str)
+	case protoBytes:
+		data, err := protoBytesValue(v)
+		if err != nil {
+			return errors.Wrapf(err, "protobuf: field %s", f.name)
+		}
+		s = strconv.Quote(string(data))
+	case protoEnum:
+		n, err := f.enumNumber(v)
+		if err != nil {
+			return err
+		}
+		s = StrVal(f.enumName(n))
+	case protoBool:
+		t, err := ToBoolE(v)
+		if err != nil {
+			return errors.Wrapf(err, "protobuf: field %s", f.name)
+		}
+		s = strconv.FormatBool(t)
+	case protoDouble, protoFloat:
+		n, err := ToFloat64E(v)
+		if err != nil {
+			return errors.Wrapf(err, "protobuf: field %s", f.name)
+		}
+		s = formatProtoFloat(n)
+	case protoUint32, protoUint64, protoFixed32, protoFixed64:
+		n, err := ToUint64E(v)
+		if err != nil {
+			return errors.Wrapf(err, "protobuf: field %s", f.name)
+		}
+		s = strconv.FormatUint(n, 10)
+	default:
+		n, err := ToInt64E(v)
+		if err != nil {
+			return errors.Wrapf(err, "protobuf: field %s", f.name)
+		}
+		s = strconv.FormatInt(n, 10)
+	}
+	buf.WriteString(indent + f.name + ": " + s + "\n")
+	return nil
+}
+
+func writeInferredText(buf *bytes.Buffer, depth int, name string, v interface{}) error {
+	indent := strings.Repeat("  ", depth)
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case map[string]interface{}:
+		buf.WriteString(indent + name + " {\n")
+		if err := (*protoMessage)(nil).writeText(buf, depth+1, v); err != nil {
+			return err
+		}
+		buf.WriteString(indent + "}\n")
+		return nil
+	case []interface{}:
+		return errors.Errorf("protobuf: nested lists are not supported in field %s", name)
+	case string:
+		buf.WriteString(indent + name + ": " + strconv.Quote(v) + "\n")
+	case []byte:
+		buf.WriteString(indent + name + ": " + strconv.Quote(string(v)) + "\n")
+	case float32:
+		buf.WriteString(indent + name + ": " + formatProtoFloat(float64(v)) + "\n")
+	case float64:
+		buf.WriteString(indent + name + ": " + formatProtoFloat(v) + "\n")
+	default:
+		buf.WriteString(indent + name + ": " + StrVal(v) + "\n")
+	}
+	return nil
+}
+
+func formatProtoFloat(n float64) string {
+	switch {
+	case math.IsInf(n, 1):
+		return "inf"
+	case math.IsInf(n, -1):
+		return "-inf"
+	case math.IsNaN(n):
+		return "nan"
+	}
+	return strconv.FormatFloat(n, 'g', -1, 64)
+}
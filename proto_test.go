@@ -0,0 +1,323 @@
+package goreflect
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func protoBytesField(num int, data []byte) []byte {
+	b := appendVarint(nil, uint64(num)<<3|wireBytes)
+	b = appendVarint(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+func protoVarintField(num int, v uint64) []byte {
+	return appendVarint(appendVarint(nil, uint64(num)<<3|wireVarint), v)
+}
+
+func protoFieldDesc(name string, num, label, typ int, typeName string) []byte {
+	b := protoBytesField(1, []byte(name))
+	b = append(b, protoVarintField(3, uint64(num))...)
+	b = append(b, protoVarintField(4, uint64(label))...)
+	b = append(b, protoVarintField(5, uint64(typ))...)
+	if typeName != "" {
+		b = append(b, protoBytesField(6, []byte(typeName))...)
+	}
+	return b
+}
+
+// testProtoCodec returns a codec for test.Config:
+//
+//	syntax = "proto3";
+//	package test;
+//	enum Kind { UNKNOWN = 0; WEB = 1; }
+//	message Config {
+//	  message Inner { bool on = 1; bytes data = 2; }
+//	  string name = 1;
+//	  repeated string tags = 2;
+//	  repeated int32 ports = 3;
+//	  Kind kind = 4;
+//	  map<string, int64> limits = 5;
+//	  Inner inner = 6;
+//	}
+func testProtoCodec(t *testing.T, binary bool) *ProtoCodec {
+	dir, err := ioutil.TempDir("", "proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	p, err := NewProtoCodec(writeTestDescriptorSet(t, dir), "test.Config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Binary = binary
+	return p
+}
+
+// writeTestDescriptorSet writes the descriptor set of testProtoCodec to
+// dir and returns its name.
+func writeTestDescriptorSet(t *testing.T, dir string) string {
+	const optional = 1
+	var kind []byte
+	kind = append(kind, protoBytesField(1, []byte("Kind"))...)
+	kind = append(kind, protoBytesField(2, append(protoBytesField(1, []byte("UNKNOWN")), protoVarintField(2, 0)...))...)
+	kind = append(kind, protoBytesField(2, append(protoBytesField(1, []byte("WEB")), protoVarintField(2, 1)...))...)
+
+	var inner []byte
+	inner = append(inner, protoBytesField(1, []byte("Inner"))...)
+	inner = append(inner, protoBytesField(2, protoFieldDesc("on", 1, optional, protoBool, ""))...)
+	inner = append(inner, protoBytesField(2, protoFieldDesc("data", 2, optional, protoBytes, ""))...)
+
+	var entry []byte
+	entry = append(entry, protoBytesField(1, []byte("LimitsEntry"))...)
+	entry = append(entry, protoBytesField(2, protoFieldDesc("key", 1, optional, protoString, ""))...)
+	entry = append(entry, protoBytesField(2, protoFieldDesc("value", 2, optional, protoInt64, ""))...)
+	entry = append(entry, protoBytesField(7, protoVarintField(7, 1))...)
+
+	var msg []byte
+	msg = append(msg, protoBytesField(1, []byte("Config"))...)
+	msg = append(msg, protoBytesField(2, protoFieldDesc("name", 1, optional, protoString, ""))...)
+	msg = append(msg, protoBytesField(2, protoFieldDesc("tags", 2, protoRepeated, protoString, ""))...)
+	msg = append(msg, protoBytesField(2, protoFieldDesc("ports", 3, protoRepeated, protoInt32, ""))...)
+	msg = append(msg, protoBytesField(2, protoFieldDesc("kind", 4, optional, protoEnum, ".test.Kind"))...)
+	msg = append(msg, protoBytesField(2, protoFieldDesc("limits", 5, protoRepeated, protoMessageT, ".test.Config.LimitsEntry"))...)
+	msg = append(msg, protoBytesField(2, protoFieldDesc("inner", 6, optional, protoMessageT, ".test.Config.Inner"))...)
+	msg = append(msg, protoBytesField(3, inner)...)
+	msg = append(msg, protoBytesField(3, entry)...)
+
+	var file []byte
+	file = append(file, protoBytesField(1, []byte("test.proto"))...)
+	file = append(file, protoBytesField(2, []byte("test"))...)
+	file = append(file, protoBytesField(4, msg)...)
+	file = append(file, protoBytesField(5, kind)...)
+	file = append(file, protoBytesField(12, []byte("proto3"))...)
+
+	name := filepath.Join(dir, "test.pb")
+	if err := ioutil.WriteFile(name, protoBytesField(1, file), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestProtoCodecText(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want map[string]interface{}
+	}{
+		{
+			name: "scalars",
+			in:   `name: "web" kind: WEB`,
+			want: map[string]interface{}{"name": "web", "kind": "WEB"},
+		},
+		{
+			name: "repeated once",
+			in:   `tags: "a" ports: 80`,
+			want: map[string]interface{}{
+				"tags":  []interface{}{"a"},
+				"ports": []interface{}{int64(80)},
+			},
+		},
+		{
+			name: "repeated twice",
+			in:   `tags: "a" tags: "b" ports: [80, 443]`,
+			want: map[string]interface{}{
+				"tags":  []interface{}{"a", "b"},
+				"ports": []interface{}{int64(80), int64(443)},
+			},
+		},
+		{
+			name: "map and message",
+			in:   `limits { key: "cpu" value: 2 } inner { on: true data: "hi" }`,
+			want: map[string]interface{}{
+				"limits": map[string]interface{}{"cpu": int64(2)},
+				"inner":  map[string]interface{}{"on": true, "data": "aGk="},
+			},
+		},
+	}
+	p := testProtoCodec(t, false)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := make(map[string]interface{})
+			if err := p.Decode(strings.NewReader(tt.in), c); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(c, tt.want) {
+				t.Errorf("got %#v, want %#v", c, tt.want)
+			}
+
+			var buf bytes.Buffer
+			if err := p.Encode(&buf, c); err != nil {
+				t.Fatal(err)
+			}
+			again := make(map[string]interface{})
+			if err := p.Decode(&buf, again); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(again, tt.want) {
+				t.Errorf("round trip got %#v, want %#v", again, tt.want)
+			}
+		})
+	}
+}
+
+func TestProtoCodecWire(t *testing.T) {
+	in := map[string]interface{}{
+		"name":   "web",
+		"tags":   []interface{}{"a", "b"},
+		"ports":  []interface{}{int64(80), int64(443)},
+		"kind":   "WEB",
+		"limits": map[string]interface{}{"cpu": int64(2)},
+		"inner":  map[string]interface{}{"on": true, "data": "aGk="},
+	}
+	p := testProtoCodec(t, true)
+	var buf bytes.Buffer
+	if err := p.Encode(&buf, in); err != nil {
+		t.Fatal(err)
+	}
+	out := make(map[string]interface{})
+	if err := p.Decode(&buf, out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("got %#v, want %#v", out, in)
+	}
+}
+
+func TestProtoCodecSchemaless(t *testing.T) {
+	const in = `name: "web" port: 80 port: 443 inner { on: true }`
+	if err := (&ProtoCodec{}).Decode(strings.NewReader(in), map[string]interface{}{}); err == nil {
+		t.Error("expected an error decoding without a descriptor")
+	}
+
+	c := make(map[string]interface{})
+	if err := (&ProtoCodec{Schemaless: true}).Decode(strings.NewReader(in), c); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"name":  "web",
+		"port":  []interface{}{int64(80), int64(443)},
+		"inner": map[string]interface{}{"on": true},
+	}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("got %#v, want %#v", c, want)
+	}
+}
+
+func TestProtoCodecErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+	}{
+		{"unknown field", `nope: 1`},
+		{"bad enum", `kind: NOPE`},
+		{"bad int", `ports: "x"`},
+		{"unterminated", `inner { on: true`},
+	}
+	p := testProtoCodec(t, false)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := p.Decode(strings.NewReader(tt.in), map[string]interface{}{}); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestProtoCodecWireErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []byte
+	}{
+		{"truncated tag", []byte{0x80}},
+		{"truncated varint", []byte{0x18, 0x80}},
+		{"length past the end", []byte{0x0a, 0x05, 'a'}},
+		{"length overflow", []byte{0x0a, 0xec, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}},
+		{"group length overflow", []byte{0x3b, 0x0a, 0xec, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01, 0x3c}},
+		{"unterminated group", []byte{0x3b, 0x08, 0x01}},
+	}
+	p := testProtoCodec(t, true)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := p.Decode(bytes.NewReader(tt.in), map[string]interface{}{}); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+	if _, err := groupLen([]byte{0x0a, 0xec, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}, 1); err == nil {
+		t.Error("groupLen: expected an error")
+	}
+}
+
+func TestProtoOptions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	set := writeTestDescriptorSet(t, dir)
+
+	const in = `name: "web" tags: "a"`
+	if err := MarshalReader(strings.NewReader(in), PROTO, map[string]interface{}{}); err == nil {
+		t.Error("expected an error decoding without a descriptor")
+	}
+
+	tests := []struct {
+		name string
+		opts ProtoOptions
+		want map[string]interface{}
+		err  bool
+	}{
+		{
+			name: "descriptor",
+			opts: ProtoOptions{DescriptorSetFile: set, Message: "test.Config"},
+			want: map[string]interface{}{"name": "web", "tags": []interface{}{"a"}},
+		},
+		{
+			name: "schemaless",
+			opts: ProtoOptions{Schemaless: true},
+			want: map[string]interface{}{"name": "web", "tags": "a"},
+		},
+		{
+			name: "unknown message",
+			opts: ProtoOptions{DescriptorSetFile: set, Message: "test.Nope"},
+			err:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := make(map[string]interface{})
+			err := MarshalReaderWith(strings.NewReader(in), PROTO, c, DecodeOptions{Proto: &tt.opts})
+			if tt.err {
+				if err == nil {
+					t.Error("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(c, tt.want) {
+				t.Errorf("got %#v, want %#v", c, tt.want)
+			}
+		})
+	}
+
+	opts := &ProtoOptions{DescriptorSetFile: set, Message: "test.Config", Binary: true}
+	var buf bytes.Buffer
+	if err := MarshalWriterWith(&buf, map[string]interface{}{"name": "web"}, PROTO, WriteOptions{Proto: opts}); err != nil {
+		t.Fatal(err)
+	}
+	c := make(map[string]interface{})
+	if err := MarshalReaderWith(&buf, PROTO, c, DecodeOptions{Proto: opts}); err != nil {
+		t.Fatal(err)
+	}
+	if c["name"] != "web" {
+		t.Errorf("wire round trip got %#v", c)
+	}
+}
@@ -0,0 +1,286 @@
+package goreflect
+
+import (
+	"bytes"
+	"go/ast"
+	goparser "go/parser"
+	"go/printer"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// StructTag is a parsed struct tag that keeps the order of its keys.
+type StructTag struct {
+	pairs []tagPair
+}
+
+// ParseStructTag parses a struct tag such as `json:"a" yaml:"b"`.
+func ParseStructTag(tag string) (*StructTag, error) {
+	pairs, err := scanTagPairs(tag)
+	if err != nil {
+		return nil, err
+	}
+	return &StructTag{pairs: pairs}, nil
+}
+
+// Keys returns the keys in order.
+func (t *StructTag) Keys() []string {
+	keys := make([]string, len(t.pairs))
+	for i, p := range t.pairs {
+		keys[i] = p.key
+	}
+	return keys
+}
+
+// Get returns the value of key.
+func (t *StructTag) Get(key string) (string, bool) {
+	for _, p := range t.pairs {
+		if p.key == key {
+			return p.value, true
+		}
+	}
+	return "", false
+}
+
+// Set replaces the value of key, or appends key when it is missing.
+func (t *StructTag) Set(key, value string) {
+	for i, p := range t.pairs {
+		if p.key == key {
+			t.pairs[i].value = value
+			return
+		}
+	}
+	t.pairs = append(t.pairs, tagPair{key, value})
+}
+
+// Delete removes every value of key.
+func (t *StructTag) Delete(key string) {
+	pairs := t.pairs[:0]
+	for _, p := range t.pairs {
+		if p.key != key {
+			pairs = append(pairs, p)
+		}
+	}
+	t.pairs = pairs
+}
+
+func (t *StructTag) String() string {
+	parts := make([]string, len(t.pairs))
+	for i, p := range t.pairs {
+		parts[i] = p.key + ":" + strconv.Quote(p.value)
+	}
+	return strings.Join(parts, " ")
+}
+
+// TagRule edits the tag of the struct field named field.
+type TagRule func(field string, tag *StructTag)
+
+// AddTag adds key to fields that lack it, naming them with name applied to
+// the Go field name, e.g. AddTag("yaml", SnakeCase, "omitempty").
+func AddTag(key string, name func(string) string, options ...string) TagRule {
+	return func(field string, tag *StructTag) {
+		if _, ok := tag.Get(key); ok {
+			return
+		}
+		tag.Set(key, strings.Join(append([]string{name(field)}, options...), ","))
+	}
+}
+
+// RemoveTag removes keys from every field.
+func RemoveTag(keys ...string) TagRule {
+	return func(field string, tag *StructTag) {
+		for _, k := range keys {
+			tag.Delete(k)
+		}
+	}
+}
+
+// CopyTag gives key to the name from the tag from, keeping the options
+// key already has.
+func CopyTag(from, to string) TagRule {
+	return func(field string, tag *StructTag) {
+		v, ok := tag.Get(from)
+		if !ok {
+			return
+		}
+		name := strings.SplitN(v, ",", 2)[0]
+		if old, ok := tag.Get(to); ok {
+			if i := strings.IndexByte(old, ','); i >= 0 {
+				name += old[i:]
+			}
+		}
+		tag.Set(to, name)
+	}
+}
+
+// TransformTag rewrites the name in key with fn, keeping its options. A
+// name of "-" is left alone.
+func TransformTag(key string, fn func(string) string) TagRule {
+	return func(field string, tag *StructTag) {
+		v, ok := tag.Get(key)
+		if !ok {
+			return
+		}
+		parts := strings.SplitN(v, ",", 2)
+		if parts[0] == "-" {
+			return
+		}
+		if parts[0] == "" {
+			parts[0] = field
+		}
+		parts[0] = fn(parts[0])
+		tag.Set(key, strings.Join(parts, ","))
+	}
+}
+
+// RewriteTags applies rules, in order, to every exported struct field in the
+// non-test Go files of the package in pkgDir. It returns the new contents
+// of the files that changed, keyed by path, and leaves the files alone;
+// see WriteRewrittenTags. Fields with malformed tags are skipped.
+func RewriteTags(pkgDir string, rules ...TagRule) (map[string][]byte, error) {
+	fset := token.NewFileSet()
+	pkgs, err := goparser.ParseDir(fset, pkgDir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, goparser.ParseComments)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	out := make(map[string][]byte)
+	for _, pkg := range pkgs {
+		for path, file := range pkg.Files {
+			changed := false
+			ast.Inspect(file, func(n ast.Node) bool {
+				st, ok := n.(*ast.StructType)
+				if !ok {
+					return true
+				}
+				var list []*ast.Field
+				for _, field := range st.Fields.List {
+					if len(field.Names) > 1 {
+						fields, ok := rewriteNames(field, rules)
+						changed = changed || ok
+						list = append(list, fields...)
+						continue
+					}
+					if rewriteField(field, rules) {
+						changed = true
+					}
+					list = append(list, field)
+				}
+				st.Fields.List = list
+				return true
+			})
+			if !changed {
+				continue
+			}
+			var buf bytes.Buffer
+			cfg := printer.Config{Mode: printer.UseSpaces | printer.TabIndent, Tabwidth: 8}
+			if err := cfg.Fprint(&buf, fset, file); err != nil {
+				return nil, errors.Wrapf(err, "printing %s", path)
+			}
+			out[path] = buf.Bytes()
+		}
+	}
+	return out, nil
+}
+
+// rewriteNames rewrites a field declaring several names, like `A, B int`,
+// which share one tag. The declaration is split into a field per name when
+// the rules give the names different tags.
+func rewriteNames(field *ast.Field, rules []TagRule) ([]*ast.Field, bool) {
+	fields := make([]*ast.Field, len(field.Names))
+	changed := false
+	for i, name := range field.Names {
+		f := &ast.Field{Names: []*ast.Ident{name}, Type: field.Type}
+		if field.Tag != nil {
+			tag := *field.Tag
+			f.Tag = &tag
+		}
+		if rewriteField(f, rules) {
+			changed = true
+		}
+		fields[i] = f
+	}
+	if !changed {
+		return []*ast.Field{field}, false
+	}
+
+	same := true
+	for _, f := range fields[1:] {
+		if (f.Tag == nil) != (fields[0].Tag == nil) || (f.Tag != nil && f.Tag.Value != fields[0].Tag.Value) {
+			same = false
+		}
+	}
+	if same {
+		field.Tag = fields[0].Tag
+		return []*ast.Field{field}, true
+	}
+	fields[0].Doc = field.Doc
+	fields[len(fields)-1].Comment = field.Comment
+	return fields, true
+}
+
+func rewriteField(field *ast.Field, rules []TagRule) bool {
+	if len(field.Names) == 0 || !ast.IsExported(field.Names[0].Name) {
+		return false
+	}
+	old := ""
+	if field.Tag != nil {
+		var err error
+		if old, err = strconv.Unquote(field.Tag.Value); err != nil {
+			return false
+		}
+	}
+	tag, err := ParseStructTag(old)
+	if err != nil {
+		return false
+	}
+	for _, rule := range rules {
+		rule(field.Names[0].Name, tag)
+	}
+
+	s := tag.String()
+	if s == old {
+		return false
+	}
+	if s == "" {
+		field.Tag = nil
+		return true
+	}
+	lit := "`" + s + "`"
+	if strings.ContainsRune(s, '`') {
+		lit = strconv.Quote(s)
+	}
+	if field.Tag == nil {
+		field.Tag = &ast.BasicLit{ValuePos: field.Type.End(), Kind: token.STRING}
+	}
+	field.Tag.Value = lit
+	return true
+}
+
+// WriteRewrittenTags writes the files returned by RewriteTags, in path
+// order, keeping their permissions.
+func WriteRewrittenTags(files map[string][]byte) error {
+	paths := make([]string, 0, len(files))
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		fi, err := os.Stat(p)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if err := ioutil.WriteFile(p, files[p], fi.Mode()); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
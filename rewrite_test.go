@@ -0,0 +1,79 @@
+package goreflect
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRewriteTags(t *testing.T) {
+	const src = `package rw
+
+type T struct {
+	// AB are two.
+	A, B int // trailing
+	c, D string ` + "`json:\"x\"`" + `
+	E    bool
+}
+`
+	tests := []struct {
+		name  string
+		rules []TagRule
+		want  string
+	}{
+		{
+			name:  "add splits shared names",
+			rules: []TagRule{AddTag("yaml", SnakeCase)},
+			want: `package rw
+
+type T struct {
+	// AB are two.
+	A int    ` + "`yaml:\"a\"`" + `
+	B int    ` + "`yaml:\"b\"`" + ` // trailing
+	c string ` + "`json:\"x\"`" + `
+	D string ` + "`json:\"x\" yaml:\"d\"`" + `
+	E bool   ` + "`yaml:\"e\"`" + `
+}
+`,
+		},
+		{
+			name:  "copy keeps shared names",
+			rules: []TagRule{CopyTag("json", "yaml")},
+			want: `package rw
+
+type T struct {
+	// AB are two.
+	A, B int    // trailing
+	c    string ` + "`json:\"x\"`" + `
+	D    string ` + "`json:\"x\" yaml:\"x\"`" + `
+	E    bool
+}
+`,
+		},
+		{
+			name:  "unchanged",
+			rules: []TagRule{RemoveTag("toml")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "rw")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			name := filepath.Join(dir, "rw.go")
+			if err := ioutil.WriteFile(name, []byte(src), 0644); err != nil {
+				t.Fatal(err)
+			}
+			out, err := RewriteTags(dir, tt.rules...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(out[name]); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,282 @@
+package goreflect
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/hashicorp/hcl2/hcldec"
+	"github.com/pkg/errors"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// SpecJSONSchema returns a JSON Schema (draft-07) document describing the
+// JSON that spec, e.g. the RootSpec returned by LoadSpecFile, produces.
+//
+// Required attributes and blocks are listed as required properties and the
+// others may be null. Block lists and sets become arrays carrying their
+// min_items and max_items, block maps nested objects keyed by label, and
+// attribute types follow their cty types. The results of transform and
+// expression specs cannot be known ahead and accept any value.
+func SpecJSONSchema(spec hcldec.Spec) ([]byte, error) {
+	root, _, err := specSchema(spec)
+	if err != nil {
+		return nil, err
+	}
+	doc := NewOrderedMap()
+	doc.Set("$schema", "http://json-schema.org/draft-07/schema#")
+	for _, k := range root.Keys() {
+		v, _ := root.Get(k)
+		doc.Set(k, v)
+	}
+	b, err := json.MarshalIndent(doc, "", "  ")
+	return b, errors.WithStack(err)
+}
+
+// specSchema returns the schema of the values of spec and whether they may
+// be null.
+func specSchema(spec hcldec.Spec) (*OrderedMap, bool, error) {
+	s := NewOrderedMap()
+	switch spec := spec.(type) {
+	case hcldec.ObjectSpec:
+		keys := make([]string, 0, len(spec))
+		for k := range spec {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		props := NewOrderedMap()
+		required := []string{}
+		for _, k := range keys {
+			p, nullable, err := specSchema(spec[k])
+			if err != nil {
+				return nil, false, errors.Wrapf(err, "%s", k)
+			}
+			if nullable {
+				p = nullableSchema(p)
+			} else {
+				required = append(required, k)
+			}
+			props.Set(k, p)
+		}
+		s.Set("type", "object")
+		s.Set("properties", props)
+		if len(required) > 0 {
+			s.Set("required", required)
+		}
+		s.Set("additionalProperties", false)
+		return s, false, nil
+
+	case hcldec.TupleSpec:
+		items := make([]interface{}, len(spec))
+		for i, elem := range spec {
+			item, nullable, err := specSchema(elem)
+			if err != nil {
+				return nil, false, errors.Wrapf(err, "[%d]", i)
+			}
+			if nullable {
+				item = nullableSchema(item)
+			}
+			items[i] = item
+		}
+		s.Set("type", "array")
+		s.Set("items", items)
+		s.Set("minItems", len(spec))
+		s.Set("maxItems", len(spec))
+		return s, false, nil
+
+	case *hcldec.AttrSpec:
+		s, err := ctyTypeSchema(spec.Type)
+		return s, !spec.Required, err
+
+	case *hcldec.LiteralSpec:
+		if spec.Value.IsNull() {
+			s.Set("type", "null")
+			return s, true, nil
+		}
+		b, err := ctyjson.Marshal(spec.Value, spec.Value.Type())
+		if err != nil {
+			return nil, false, errors.WithStack(err)
+		}
+		s.Set("const", json.RawMessage(b))
+		return s, false, nil
+
+	case *hcldec.BlockSpec:
+		s, _, err := specSchema(spec.Nested)
+		return s, !spec.Required, errors.Wrapf(err, "block %s", spec.TypeName)
+
+	case *hcldec.BlockListSpec:
+		return blockListSchema(spec.TypeName, spec.Nested, spec.MinItems, spec.MaxItems, false)
+	case *hcldec.BlockTupleSpec:
+		return blockListSchema(spec.TypeName, spec.Nested, spec.MinItems, spec.MaxItems, false)
+	case *hcldec.BlockSetSpec:
+		return blockListSchema(spec.TypeName, spec.Nested, spec.MinItems, spec.MaxItems, true)
+
+	case *hcldec.BlockMapSpec:
+		return blockMapSchema(spec.TypeName, spec.Nested, len(spec.LabelNames))
+	case *hcldec.BlockObjectSpec:
+		return blockMapSchema(spec.TypeName, spec.Nested, len(spec.LabelNames))
+
+	case *hcldec.BlockAttrsSpec:
+		elem, err := ctyTypeSchema(spec.ElementType)
+		if err != nil {
+			return nil, false, errors.Wrapf(err, "block %s", spec.TypeName)
+		}
+		s.Set("type", "object")
+		s.Set("additionalProperties", elem)
+		return s, !spec.Required, nil
+
+	case *hcldec.BlockLabelSpec:
+		s.Set("type", "string")
+		return s, false, nil
+
+	case *hcldec.DefaultSpec:
+		p, _, err := specSchema(spec.Primary)
+		if err != nil {
+			return nil, false, err
+		}
+		d, nullable, err := specSchema(spec.Default)
+		if err != nil {
+			return nil, false, err
+		}
+		pb, _ := json.Marshal(p)
+		db, _ := json.Marshal(d)
+		if string(pb) == string(db) {
+			return p, nullable, nil
+		}
+		s.Set("anyOf", []interface{}{p, d})
+		return s, nullable, nil
+
+	case *hcldec.ExprSpec, *hcldec.TransformExprSpec, *hcldec.TransformFuncSpec:
+		return s, true, nil
+	}
+	return nil, false, errors.Errorf("unsupported spec %T", spec)
+}
+
+func blockListSchema(name string, nested hcldec.Spec, min, max int, unique bool) (*OrderedMap, bool, error) {
+	item, nullable, err := specSchema(nested)
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "block %s", name)
+	}
+	if nullable {
+		item = nullableSchema(item)
+	}
+	s := NewOrderedMap()
+	s.Set("type", "array")
+	s.Set("items", item)
+	if min > 0 {
+		s.Set("minItems", min)
+	}
+	if max > 0 {
+		s.Set("maxItems", max)
+	}
+	if unique {
+		s.Set("uniqueItems", true)
+	}
+	return s, false, nil
+}
+
+// blockMapSchema nests an object keyed by label for each of the labels.
+func blockMapSchema(name string, nested hcldec.Spec, labels int) (*OrderedMap, bool, error) {
+	s, nullable, err := specSchema(nested)
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "block %s", name)
+	}
+	if nullable {
+		s = nullableSchema(s)
+	}
+	for i := 0; i < labels; i++ {
+		outer := NewOrderedMap()
+		outer.Set("type", "object")
+		outer.Set("additionalProperties", s)
+		s = outer
+	}
+	return s, false, nil
+}
+
+// nullableSchema lets s also accept null.
+func nullableSchema(s *OrderedMap) *OrderedMap {
+	if s.Len() == 0 {
+		return s
+	}
+	if t, ok := s.Get("type"); ok {
+		if name, ok := t.(string); ok && name != "null" {
+			s.Set("type", []string{name, "null"})
+		}
+		return s
+	}
+	null := NewOrderedMap()
+	null.Set("type", "null")
+	out := NewOrderedMap()
+	out.Set("anyOf", []interface{}{s, null})
+	return out
+}
+
+// ctyTypeSchema returns the schema of the JSON encoding of values of t.
+func ctyTypeSchema(t cty.Type) (*OrderedMap, error) {
+	s := NewOrderedMap()
+	switch {
+	case t == cty.DynamicPseudoType:
+	case t == cty.String:
+		s.Set("type", "string")
+	case t == cty.Number:
+		s.Set("type", "number")
+	case t == cty.Bool:
+		s.Set("type", "boolean")
+	case t.IsListType() || t.IsSetType():
+		elem, err := ctyTypeSchema(t.ElementType())
+		if err != nil {
+			return nil, err
+		}
+		s.Set("type", "array")
+		s.Set("items", elem)
+		if t.IsSetType() {
+			s.Set("uniqueItems", true)
+		}
+	case t.IsMapType():
+		elem, err := ctyTypeSchema(t.ElementType())
+		if err != nil {
+			return nil, err
+		}
+		s.Set("type", "object")
+		s.Set("additionalProperties", elem)
+	case t.IsObjectType():
+		atys := t.AttributeTypes()
+		names := make([]string, 0, len(atys))
+		for name := range atys {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		props := NewOrderedMap()
+		for _, name := range names {
+			p, err := ctyTypeSchema(atys[name])
+			if err != nil {
+				return nil, err
+			}
+			// object attributes are always present, but may be null
+			props.Set(name, nullableSchema(p))
+		}
+		s.Set("type", "object")
+		s.Set("properties", props)
+		if len(names) > 0 {
+			s.Set("required", names)
+		}
+		s.Set("additionalProperties", false)
+	case t.IsTupleType():
+		etys := t.TupleElementTypes()
+		items := make([]interface{}, len(etys))
+		for i, ety := range etys {
+			item, err := ctyTypeSchema(ety)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = item
+		}
+		s.Set("type", "array")
+		s.Set("items", items)
+		s.Set("minItems", len(etys))
+		s.Set("maxItems", len(etys))
+	default:
+		return nil, errors.Errorf("type %s has no JSON encoding", t.FriendlyName())
+	}
+	return s, nil
+}
@@ -0,0 +1,132 @@
+package goreflect
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/hcl2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestSpecJSONSchema(t *testing.T) {
+	port := hcldec.ObjectSpec{
+		"port": &hcldec.AttrSpec{Name: "port", Type: cty.Number, Required: true},
+	}
+	tests := []struct {
+		name string
+		spec hcldec.Spec
+		want string
+	}{
+		{
+			name: "attributes",
+			spec: hcldec.ObjectSpec{
+				"name": &hcldec.AttrSpec{Name: "name", Type: cty.String, Required: true},
+				"tags": &hcldec.AttrSpec{Name: "tags", Type: cty.Set(cty.String)},
+				"any":  &hcldec.AttrSpec{Name: "any", Type: cty.DynamicPseudoType},
+			},
+			want: `{"type":"object","properties":{` +
+				`"any":{},` +
+				`"name":{"type":"string"},` +
+				`"tags":{"type":["array","null"],"items":{"type":"string"},"uniqueItems":true}` +
+				`},"required":["name"],"additionalProperties":false}`,
+		},
+		{
+			name: "block list min and max",
+			spec: &hcldec.BlockListSpec{TypeName: "server", Nested: port, MinItems: 1, MaxItems: 3},
+			want: `{"type":"array","items":{"type":"object","properties":{"port":{"type":"number"}},` +
+				`"required":["port"],"additionalProperties":false},"minItems":1,"maxItems":3}`,
+		},
+		{
+			name: "block list without limits",
+			spec: &hcldec.BlockListSpec{TypeName: "server", Nested: &hcldec.AttrSpec{Name: "port", Type: cty.Number}},
+			want: `{"type":"array","items":{"type":["number","null"]}}`,
+		},
+		{
+			name: "block set",
+			spec: &hcldec.BlockSetSpec{TypeName: "tag", Nested: &hcldec.BlockLabelSpec{Index: 0, Name: "name"}, MaxItems: 2},
+			want: `{"type":"array","items":{"type":"string"},"maxItems":2,"uniqueItems":true}`,
+		},
+		{
+			name: "block map",
+			spec: &hcldec.BlockMapSpec{TypeName: "rule", LabelNames: []string{"kind", "name"}, Nested: port},
+			want: `{"type":"object","additionalProperties":{"type":"object","additionalProperties":` +
+				`{"type":"object","properties":{"port":{"type":"number"}},"required":["port"],"additionalProperties":false}}}`,
+		},
+		{
+			name: "default of the same schema",
+			spec: &hcldec.DefaultSpec{
+				Primary: &hcldec.AttrSpec{Name: "port", Type: cty.Number},
+				Default: &hcldec.LiteralSpec{Value: cty.NumberIntVal(80)},
+			},
+			want: `{"anyOf":[{"type":"number"},{"const":80}]}`,
+		},
+		{
+			name: "default of a different schema",
+			spec: hcldec.ObjectSpec{
+				"port": &hcldec.DefaultSpec{
+					Primary: &hcldec.AttrSpec{Name: "port", Type: cty.Number},
+					Default: &hcldec.AttrSpec{Name: "listen", Type: cty.String},
+				},
+			},
+			want: `{"type":"object","properties":{"port":{"anyOf":[` +
+				`{"anyOf":[{"type":"number"},{"type":"string"}]},{"type":"null"}]}},` +
+				`"additionalProperties":false}`,
+		},
+		{
+			name: "default of equal schemas",
+			spec: &hcldec.DefaultSpec{
+				Primary: &hcldec.AttrSpec{Name: "port", Type: cty.Number, Required: true},
+				Default: &hcldec.AttrSpec{Name: "listen", Type: cty.Number, Required: true},
+			},
+			want: `{"type":"number"}`,
+		},
+		{
+			name: "null literal",
+			spec: &hcldec.LiteralSpec{Value: cty.NullVal(cty.String)},
+			want: `{"type":"null"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := SpecJSONSchema(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var doc map[string]interface{}
+			if err := json.Unmarshal(b, &doc); err != nil {
+				t.Fatal(err)
+			}
+			if doc["$schema"] != "http://json-schema.org/draft-07/schema#" {
+				t.Errorf("got $schema %v", doc["$schema"])
+			}
+			var got bytes.Buffer
+			if err := json.Compact(&got, b); err != nil {
+				t.Fatal(err)
+			}
+			want := `{"$schema":"http://json-schema.org/draft-07/schema#",` + tt.want[1:]
+			if got.String() != want {
+				t.Errorf("got:\n%s\nwant:\n%s", got.String(), want)
+			}
+		})
+	}
+}
+
+func TestSpecJSONSchemaErrors(t *testing.T) {
+	capsule := cty.Capsule("thing", reflect.TypeOf(0))
+	tests := []struct {
+		name string
+		spec hcldec.Spec
+	}{
+		{"capsule attribute", &hcldec.AttrSpec{Name: "x", Type: capsule}},
+		{"capsule in a block", &hcldec.BlockListSpec{TypeName: "b", Nested: &hcldec.AttrSpec{Name: "x", Type: cty.List(capsule)}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := SpecJSONSchema(tt.spec); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,496 @@
+package goreflect
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+	"github.com/zclconf/go-cty/cty/function/stdlib"
+	"github.com/zclconf/go-cty/cty/gocty"
+)
+
+// specFuncs are the functions available to spec files and the HCL they
+// decode: the go-cty stdlib, less the functions HCL offers as operators,
+// the collection, string and time functions later go-cty releases and
+// Terraform provide, and goreflect's own string helpers. Functions whose
+// result changes from call to call, such as timestamp and the random
+// string helpers, are left out to keep decoding repeatable.
+var specFuncs = map[string]function.Function{
+	"abs":                    stdlib.AbsoluteFunc,
+	"coalesce":               stdlib.CoalesceFunc,
+	"concat":                 stdlib.ConcatFunc,
+	"csvdecode":              stdlib.CSVDecodeFunc,
+	"format":                 stdlib.FormatFunc,
+	"formatdate":             stdlib.FormatDateFunc,
+	"formatlist":             stdlib.FormatListFunc,
+	"hasindex":               stdlib.HasIndexFunc,
+	"int":                    stdlib.IntFunc,
+	"jsondecode":             stdlib.JSONDecodeFunc,
+	"jsonencode":             stdlib.JSONEncodeFunc,
+	"length":                 stdlib.LengthFunc,
+	"lower":                  stdlib.LowerFunc,
+	"max":                    stdlib.MaxFunc,
+	"min":                    stdlib.MinFunc,
+	"reverse":                stdlib.ReverseFunc,
+	"sethaselement":          stdlib.SetHasElementFunc,
+	"setintersection":        stdlib.SetIntersectionFunc,
+	"setsubtract":            stdlib.SetSubtractFunc,
+	"setsymmetricdifference": stdlib.SetSymmetricDifferenceFunc,
+	"setunion":               stdlib.SetUnionFunc,
+	"strlen":                 stdlib.StrlenFunc,
+	"substr":                 stdlib.SubstrFunc,
+	"upper":                  stdlib.UpperFunc,
+
+	"distinct": distinctFunc,
+	"flatten":  flattenFunc,
+	"join":     joinFunc,
+	"keys":     keysFunc,
+	"merge":    mergeFunc,
+	"regex":    regexFunc,
+	"replace":  replaceFunc,
+	"sort":     sortFunc,
+	"split":    splitFunc,
+	"timeadd":  timeAddFunc,
+	"values":   valuesFunc,
+
+	"abbrev":         intStringFunc("width", Abbrev),
+	"abbrevboth":     abbrevBothFunc,
+	"base32decode":   decodeStringFunc(base32.StdEncoding.DecodeString),
+	"base32encode":   stringFunc(Base32encode),
+	"base64decode":   decodeStringFunc(base64.StdEncoding.DecodeString),
+	"base64encode":   stringFunc(Base64encode),
+	"camelcase":      stringFunc(CamelCase),
+	"indent":         intStringFunc("spaces", Indent),
+	"initials":       stringFunc(Initials),
+	"kebabcase":      stringFunc(KebabCase),
+	"levenshtein":    levenshteinFunc,
+	"lowercamelcase": stringFunc(LowerCamelCase),
+	"nindent":        intStringFunc("spaces", Nindent),
+	"plural":         pluralFunc,
+	"snakecase":      stringFunc(SnakeCase),
+	"trunc":          intStringFunc("length", Trunc),
+	"untitle":        stringFunc(Untitle),
+}
+
+// RegisterSpecFunc makes fn available as name to spec files and the HCL
+// they decode, replacing any function of that name. It is not safe to call
+// while specs are loaded or decoded; register functions from init.
+func RegisterSpecFunc(name string, fn function.Function) {
+	specFuncs[name] = fn
+}
+
+// SpecFuncs returns a copy of the functions available to spec files, for
+// use in other evaluation contexts.
+func SpecFuncs() map[string]function.Function {
+	funcs := make(map[string]function.Function, len(specFuncs))
+	for k, v := range specFuncs {
+		funcs[k] = v
+	}
+	return funcs
+}
+
+func stringFunc(fn func(string) string) function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{{Name: "str", Type: cty.String}},
+		Type:   function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			return cty.StringVal(fn(args[0].AsString())), nil
+		},
+	})
+}
+
+func decodeStringFunc(decode func(string) ([]byte, error)) function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{{Name: "str", Type: cty.String}},
+		Type:   function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			b, err := decode(args[0].AsString())
+			if err != nil {
+				return cty.UnknownVal(cty.String), function.NewArgError(0, err)
+			}
+			return cty.StringVal(string(b)), nil
+		},
+	})
+}
+
+func intStringFunc(name string, fn func(int, string) string) function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{
+			{Name: name, Type: cty.Number},
+			{Name: "str", Type: cty.String},
+		},
+		Type: function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			var n int
+			if err := gocty.FromCtyValue(args[0], &n); err != nil {
+				return cty.UnknownVal(cty.String), function.NewArgError(0, err)
+			}
+			if n < 0 {
+				return cty.UnknownVal(cty.String), function.NewArgErrorf(0, "%s must not be negative", name)
+			}
+			return cty.StringVal(fn(n, args[1].AsString())), nil
+		},
+	})
+}
+
+var abbrevBothFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{Name: "left", Type: cty.Number},
+		{Name: "right", Type: cty.Number},
+		{Name: "str", Type: cty.String},
+	},
+	Type: function.StaticReturnType(cty.String),
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		var left, right int
+		if err := gocty.FromCtyValue(args[0], &left); err != nil {
+			return cty.UnknownVal(cty.String), function.NewArgError(0, err)
+		}
+		if err := gocty.FromCtyValue(args[1], &right); err != nil {
+			return cty.UnknownVal(cty.String), function.NewArgError(1, err)
+		}
+		return cty.StringVal(Abbrevboth(left, right, args[2].AsString())), nil
+	},
+})
+
+var pluralFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{Name: "one", Type: cty.String},
+		{Name: "many", Type: cty.String},
+		{Name: "count", Type: cty.Number},
+	},
+	Type: function.StaticReturnType(cty.String),
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		var count int
+		if err := gocty.FromCtyValue(args[2], &count); err != nil {
+			return cty.UnknownVal(cty.String), function.NewArgError(2, err)
+		}
+		return cty.StringVal(Plural(args[0].AsString(), args[1].AsString(), count)), nil
+	},
+})
+
+var levenshteinFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{Name: "a", Type: cty.String},
+		{Name: "b", Type: cty.String},
+	},
+	Type: function.StaticReturnType(cty.Number),
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		return cty.NumberIntVal(int64(Levenshtein(args[0].AsString(), args[1].AsString()))), nil
+	},
+})
+
+var joinFunc = function.New(&function.Spec{
+	Params: []function.Parameter{{Name: "separator", Type: cty.String}},
+	VarParam: &function.Parameter{
+		Name: "lists",
+		Type: cty.List(cty.String),
+	},
+	Type: function.StaticReturnType(cty.String),
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		var items []string
+		for i, list := range args[1:] {
+			for it := list.ElementIterator(); it.Next(); {
+				_, v := it.Element()
+				if v.IsNull() {
+					return cty.UnknownVal(cty.String), function.NewArgErrorf(i+1, "cannot join a null value")
+				}
+				items = append(items, v.AsString())
+			}
+		}
+		return cty.StringVal(strings.Join(items, args[0].AsString())), nil
+	},
+})
+
+var splitFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{Name: "separator", Type: cty.String},
+		{Name: "str", Type: cty.String},
+	},
+	Type: function.StaticReturnType(cty.List(cty.String)),
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		parts := strings.Split(args[1].AsString(), args[0].AsString())
+		vals := make([]cty.Value, len(parts))
+		for i, p := range parts {
+			vals[i] = cty.StringVal(p)
+		}
+		return cty.ListVal(vals), nil
+	},
+})
+
+// regexFunc returns the first match of a pattern in a string: the match
+// itself when the pattern has no groups, a map of named groups when all
+// are named, and a list of the groups otherwise.
+var regexFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{Name: "pattern", Type: cty.String},
+		{Name: "str", Type: cty.String},
+	},
+	Type: func(args []cty.Value) (cty.Type, error) {
+		if !args[0].IsKnown() {
+			return cty.DynamicPseudoType, nil
+		}
+		re, err := regexp.Compile(args[0].AsString())
+		if err != nil {
+			return cty.NilType, function.NewArgError(0, err)
+		}
+		return regexType(re), nil
+	},
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		re := regexp.MustCompile(args[0].AsString())
+		m := re.FindStringSubmatch(args[1].AsString())
+		if m == nil {
+			return cty.UnknownVal(retType), function.NewArgErrorf(1, "pattern did not match")
+		}
+		switch {
+		case retType == cty.String:
+			return cty.StringVal(m[0]), nil
+		case retType.IsMapType():
+			groups := make(map[string]cty.Value, len(m)-1)
+			for i, name := range re.SubexpNames()[1:] {
+				groups[name] = cty.StringVal(m[i+1])
+			}
+			return cty.MapVal(groups), nil
+		}
+		groups := make([]cty.Value, len(m)-1)
+		for i, g := range m[1:] {
+			groups[i] = cty.StringVal(g)
+		}
+		return cty.ListVal(groups), nil
+	},
+})
+
+func regexType(re *regexp.Regexp) cty.Type {
+	if re.NumSubexp() == 0 {
+		return cty.String
+	}
+	for _, name := range re.SubexpNames()[1:] {
+		if name == "" {
+			return cty.List(cty.String)
+		}
+	}
+	return cty.Map(cty.String)
+}
+
+// replaceFunc replaces every occurrence of a substring, or of a regexp
+// when the substring is wrapped in slashes, as in Terraform.
+var replaceFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{Name: "str", Type: cty.String},
+		{Name: "substr", Type: cty.String},
+		{Name: "replace", Type: cty.String},
+	},
+	Type: function.StaticReturnType(cty.String),
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		str, substr, repl := args[0].AsString(), args[1].AsString(), args[2].AsString()
+		if len(substr) > 1 && substr[0] == '/' && substr[len(substr)-1] == '/' {
+			re, err := regexp.Compile(substr[1 : len(substr)-1])
+			if err != nil {
+				return cty.UnknownVal(cty.String), function.NewArgError(1, err)
+			}
+			return cty.StringVal(re.ReplaceAllString(str, repl)), nil
+		}
+		return cty.StringVal(strings.Replace(str, substr, repl, -1)), nil
+	},
+})
+
+var keysFunc = function.New(&function.Spec{
+	Params: []function.Parameter{{Name: "inputMap", Type: cty.DynamicPseudoType}},
+	Type: func(args []cty.Value) (cty.Type, error) {
+		ty := args[0].Type()
+		if !ty.IsMapType() && !ty.IsObjectType() && ty != cty.DynamicPseudoType {
+			return cty.NilType, function.NewArgErrorf(0, "must be a map or an object")
+		}
+		return cty.List(cty.String), nil
+	},
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		if args[0].LengthInt() == 0 {
+			return cty.ListValEmpty(cty.String), nil
+		}
+		var keys []cty.Value
+		for it := args[0].ElementIterator(); it.Next(); {
+			k, _ := it.Element()
+			keys = append(keys, k)
+		}
+		return cty.ListVal(keys), nil
+	},
+})
+
+var valuesFunc = function.New(&function.Spec{
+	Params: []function.Parameter{{Name: "inputMap", Type: cty.DynamicPseudoType}},
+	Type: func(args []cty.Value) (cty.Type, error) {
+		ty := args[0].Type()
+		switch {
+		case ty.IsMapType():
+			return cty.List(ty.ElementType()), nil
+		case ty.IsObjectType():
+			atys := ty.AttributeTypes()
+			names := make([]string, 0, len(atys))
+			for name := range atys {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			etys := make([]cty.Type, len(names))
+			for i, name := range names {
+				etys[i] = atys[name]
+			}
+			return cty.Tuple(etys), nil
+		case ty == cty.DynamicPseudoType:
+			return cty.DynamicPseudoType, nil
+		}
+		return cty.NilType, function.NewArgErrorf(0, "must be a map or an object")
+	},
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		vals := args[0].AsValueSlice()
+		if retType.IsTupleType() {
+			if len(vals) == 0 {
+				return cty.EmptyTupleVal, nil
+			}
+			return cty.TupleVal(vals), nil
+		}
+		if len(vals) == 0 {
+			return cty.ListValEmpty(retType.ElementType()), nil
+		}
+		return cty.ListVal(vals), nil
+	},
+})
+
+// mergeFunc merges maps and objects into one object; later arguments win.
+var mergeFunc = function.New(&function.Spec{
+	VarParam: &function.Parameter{
+		Name:      "maps",
+		Type:      cty.DynamicPseudoType,
+		AllowNull: true,
+	},
+	Type: function.StaticReturnType(cty.DynamicPseudoType),
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		attrs := make(map[string]cty.Value)
+		for i, arg := range args {
+			if arg.IsNull() {
+				continue
+			}
+			if ty := arg.Type(); !ty.IsMapType() && !ty.IsObjectType() {
+				return cty.DynamicVal, function.NewArgErrorf(i, "must be a map or an object")
+			}
+			for it := arg.ElementIterator(); it.Next(); {
+				k, v := it.Element()
+				attrs[k.AsString()] = v
+			}
+		}
+		return cty.ObjectVal(attrs), nil
+	},
+})
+
+// flattenFunc flattens nested lists, sets and tuples into one tuple.
+var flattenFunc = function.New(&function.Spec{
+	Params: []function.Parameter{{Name: "list", Type: cty.DynamicPseudoType}},
+	Type: func(args []cty.Value) (cty.Type, error) {
+		if !isSequence(args[0].Type()) && args[0].Type() != cty.DynamicPseudoType {
+			return cty.NilType, function.NewArgErrorf(0, "must be a list, set or tuple")
+		}
+		return cty.DynamicPseudoType, nil
+	},
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		if !args[0].IsWhollyKnown() {
+			return cty.DynamicVal, nil
+		}
+		out := flattenValue(args[0], nil)
+		if len(out) == 0 {
+			return cty.EmptyTupleVal, nil
+		}
+		return cty.TupleVal(out), nil
+	},
+})
+
+func isSequence(ty cty.Type) bool {
+	return ty.IsListType() || ty.IsSetType() || ty.IsTupleType()
+}
+
+func flattenValue(v cty.Value, out []cty.Value) []cty.Value {
+	if v.IsNull() || !isSequence(v.Type()) {
+		return append(out, v)
+	}
+	for it := v.ElementIterator(); it.Next(); {
+		_, elem := it.Element()
+		out = flattenValue(elem, out)
+	}
+	return out
+}
+
+// distinctFunc drops repeated elements of a list, keeping the first.
+var distinctFunc = function.New(&function.Spec{
+	Params: []function.Parameter{{Name: "list", Type: cty.List(cty.DynamicPseudoType)}},
+	Type: func(args []cty.Value) (cty.Type, error) {
+		return args[0].Type(), nil
+	},
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		if !args[0].IsWhollyKnown() {
+			return cty.UnknownVal(retType), nil
+		}
+		var out []cty.Value
+	elems:
+		for _, v := range args[0].AsValueSlice() {
+			for _, seen := range out {
+				if v.RawEquals(seen) {
+					continue elems
+				}
+			}
+			out = append(out, v)
+		}
+		if len(out) == 0 {
+			return args[0], nil
+		}
+		return cty.ListVal(out), nil
+	},
+})
+
+// sortFunc sorts a list of strings lexicographically.
+var sortFunc = function.New(&function.Spec{
+	Params: []function.Parameter{{Name: "list", Type: cty.List(cty.String)}},
+	Type:   function.StaticReturnType(cty.List(cty.String)),
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		if !args[0].IsWhollyKnown() {
+			return cty.UnknownVal(retType), nil
+		}
+		vals := args[0].AsValueSlice()
+		if len(vals) == 0 {
+			return args[0], nil
+		}
+		strs := make([]string, len(vals))
+		for i, v := range vals {
+			if v.IsNull() {
+				return cty.UnknownVal(retType), function.NewArgErrorf(0, "cannot sort a null value")
+			}
+			strs[i] = v.AsString()
+		}
+		sort.Strings(strs)
+		for i, s := range strs {
+			vals[i] = cty.StringVal(s)
+		}
+		return cty.ListVal(vals), nil
+	},
+})
+
+// timeAddFunc adds a duration such as "1h30m" to an RFC 3339 timestamp.
+var timeAddFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{Name: "timestamp", Type: cty.String},
+		{Name: "duration", Type: cty.String},
+	},
+	Type: function.StaticReturnType(cty.String),
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		ts, err := time.Parse(time.RFC3339, args[0].AsString())
+		if err != nil {
+			return cty.UnknownVal(cty.String), function.NewArgError(0, err)
+		}
+		d, err := time.ParseDuration(args[1].AsString())
+		if err != nil {
+			return cty.UnknownVal(cty.String), function.NewArgError(1, err)
+		}
+		return cty.StringVal(ts.Add(d).Format(time.RFC3339)), nil
+	},
+})
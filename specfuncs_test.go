@@ -0,0 +1,54 @@
+package goreflect
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestSpecFuncs(t *testing.T) {
+	tests := []struct {
+		name string
+		args []cty.Value
+		want cty.Value
+		err  string
+	}{
+		{"indent", []cty.Value{cty.NumberIntVal(2), cty.StringVal("a\nb")}, cty.StringVal("  a\n  b"), ""},
+		{"nindent", []cty.Value{cty.NumberIntVal(1), cty.StringVal("a")}, cty.StringVal("\n a"), ""},
+		{"trunc", []cty.Value{cty.NumberIntVal(3), cty.StringVal("abcdef")}, cty.StringVal("abc"), ""},
+		{"abbrev", []cty.Value{cty.NumberIntVal(5), cty.StringVal("abcdef")}, cty.StringVal("ab..."), ""},
+		{"indent", []cty.Value{cty.NumberIntVal(-1), cty.StringVal("a")}, cty.NilVal, "spaces must not be negative"},
+		{"nindent", []cty.Value{cty.NumberIntVal(-1), cty.StringVal("a")}, cty.NilVal, "spaces must not be negative"},
+		{"trunc", []cty.Value{cty.NumberIntVal(-2), cty.StringVal("a")}, cty.NilVal, "length must not be negative"},
+		{"trunc", []cty.Value{cty.NumberFloatVal(1.5), cty.StringVal("a")}, cty.NilVal, "whole number"},
+		{"snakecase", []cty.Value{cty.StringVal("HTTPServer")}, cty.StringVal("http_server"), ""},
+		{"base64decode", []cty.Value{cty.StringVal("!")}, cty.NilVal, "illegal base64"},
+		{"timeadd", []cty.Value{cty.StringVal("2020-01-01T00:00:00Z"), cty.StringVal("1h30m")}, cty.StringVal("2020-01-01T01:30:00Z"), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := specFuncs[tt.name].Call(tt.args)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.RawEquals(tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSpecFuncsRepeatable(t *testing.T) {
+	for _, name := range []string{"timestamp", "uuid", "randalpha"} {
+		if _, ok := SpecFuncs()[name]; ok {
+			t.Errorf("%s is registered", name)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package goreflect
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hclwrite"
+	"github.com/pkg/errors"
+	"github.com/zclconf/go-cty/cty"
+)
+
+var (
+	hclExpressionType = reflect.TypeOf((*hcl.Expression)(nil)).Elem()
+	ctyValueType      = reflect.TypeOf(cty.Value{})
+)
+
+// GenerateSpec returns an hcldec spec file, as read by LoadSpecFile, for
+// the struct v or the struct v points to, following its gohcl tags:
+//
+//	type Config struct {
+//		Name    string            `hcl:"name"`
+//		Port    *int              `hcl:"port"`
+//		Tags    map[string]string `hcl:"tags,optional"`
+//		Servers []Server          `hcl:"server,block"`
+//	}
+//
+// Attributes are required unless tagged optional or held by a pointer.
+// Blocks become block specs, required unless held by a pointer, and slices
+// of them block_list specs; blocks whose struct has label fields become
+// block_map specs keyed by their labels. Go types map to type expressions
+// such as list(string), map(number) and object({...}), the latter named by
+// the cty tags of the struct, or its hcl tags. Fields without an hcl tag
+// are skipped, except embedded structs, whose fields are promoted.
+func GenerateSpec(v interface{}) ([]byte, error) {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, errors.Errorf("GenerateSpec: expected a struct, got %T", v)
+	}
+	g := &specGen{seen: map[reflect.Type]bool{t: true}}
+	var buf bytes.Buffer
+	buf.WriteString("object {\n")
+	if err := g.object(&buf, t); err != nil {
+		return nil, err
+	}
+	buf.WriteString("}\n")
+	return hclwrite.Format(buf.Bytes()), nil
+}
+
+type specGen struct {
+	seen map[reflect.Type]bool
+}
+
+// object writes the specs of the fields of t.
+func (g *specGen) object(buf *bytes.Buffer, t reflect.Type) error {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, opts, err := fieldName(t, i, "hcl")
+		if err != nil {
+			return errors.Wrapf(err, "field %s", f.Name)
+		}
+		if f.Anonymous && name == "" {
+			et := f.Type
+			if et.Kind() == reflect.Ptr {
+				et = et.Elem()
+			}
+			if et.Kind() == reflect.Struct {
+				if err := g.object(buf, et); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if name == "" || name == "-" || f.PkgPath != "" || opts["label"] || opts["remain"] {
+			continue
+		}
+
+		if opts["block"] {
+			if err := g.block(buf, name, f.Type); err != nil {
+				return errors.Wrapf(err, "field %s", f.Name)
+			}
+			continue
+		}
+		typ, err := g.typeExpr(f.Type)
+		if err != nil {
+			return errors.Wrapf(err, "field %s", f.Name)
+		}
+		fmt.Fprintf(buf, "attr %s {\ntype = %s\n", strconv.Quote(name), typ)
+		if !opts["optional"] && f.Type.Kind() != reflect.Ptr {
+			buf.WriteString("required = true\n")
+		}
+		buf.WriteString("}\n")
+	}
+	return nil
+}
+
+func (g *specGen) block(buf *bytes.Buffer, name string, t reflect.Type) error {
+	required, list := true, false
+	switch t.Kind() {
+	case reflect.Ptr:
+		t, required = t.Elem(), false
+	case reflect.Slice, reflect.Array:
+		t, list = t.Elem(), true
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+	}
+	if t.Kind() != reflect.Struct {
+		return errors.Errorf("block %s must be a struct, not %s", name, t)
+	}
+	if g.seen[t] {
+		return errors.Errorf("block %s nests %s within itself", name, t)
+	}
+	g.seen[t] = true
+	defer delete(g.seen, t)
+
+	var labels []string
+	for i := 0; i < t.NumField(); i++ {
+		label, opts, err := fieldName(t, i, "hcl")
+		if err != nil {
+			return errors.Wrapf(err, "field %s", t.Field(i).Name)
+		}
+		if opts["label"] {
+			labels = append(labels, strconv.Quote(label))
+		}
+	}
+
+	switch {
+	case len(labels) > 0:
+		fmt.Fprintf(buf, "block_map %s {\nlabels = [%s]\n", strconv.Quote(name), strings.Join(labels, ", "))
+	case list:
+		fmt.Fprintf(buf, "block_list %s {\n", strconv.Quote(name))
+	default:
+		fmt.Fprintf(buf, "block %s {\n", strconv.Quote(name))
+		if required {
+			buf.WriteString("required = true\n")
+		}
+	}
+	buf.WriteString("object {\n")
+	if err := g.object(buf, t); err != nil {
+		return err
+	}
+	buf.WriteString("}\n}\n")
+	return nil
+}
+
+// typeExpr returns the type expression for values of t.
+func (g *specGen) typeExpr(t reflect.Type) (string, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t == hclExpressionType || t == ctyValueType:
+		return "any", nil
+	case t == durationType || t == timeType || t.Implements(textMarshalerType):
+		return "string", nil
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+		return "any", nil
+	case reflect.String:
+		return "string", nil
+	case reflect.Bool:
+		return "bool", nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number", nil
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "string", nil
+		}
+		elem, err := g.typeExpr(t.Elem())
+		return "list(" + elem + ")", err
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return "", errors.Errorf("map keys must be strings, not %s", t.Key())
+		}
+		elem, err := g.typeExpr(t.Elem())
+		return "map(" + elem + ")", err
+	case reflect.Struct:
+		return g.objectType(t)
+	}
+	return "", errors.Errorf("%s has no HCL type", t)
+}
+
+func (g *specGen) objectType(t reflect.Type) (string, error) {
+	if g.seen[t] {
+		return "", errors.Errorf("%s contains itself", t)
+	}
+	g.seen[t] = true
+	defer delete(g.seen, t)
+
+	attrs := make(map[string]string)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name, _, err := fieldName(t, i, "cty")
+		if err == nil && name == "" {
+			name, _, err = fieldName(t, i, "hcl")
+		}
+		if err != nil {
+			return "", errors.Wrapf(err, "field %s", f.Name)
+		}
+		if name == "" || name == "-" {
+			continue
+		}
+		typ, err := g.typeExpr(f.Type)
+		if err != nil {
+			return "", errors.Wrapf(err, "field %s", f.Name)
+		}
+		attrs[name] = typ
+	}
+
+	names := make([]string, 0, len(attrs))
+	for name := range attrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = hclKeyText(name) + " = " + attrs[name]
+	}
+	return "object({" + strings.Join(parts, ", ") + "})", nil
+}
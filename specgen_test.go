@@ -0,0 +1,161 @@
+package goreflect
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type specGenServer struct {
+	Name  string   `hcl:"name,label"`
+	Port  int      `hcl:"port"`
+	Hosts []string `hcl:"hosts,optional"`
+}
+
+type specGenLog struct {
+	Level string `hcl:"level"`
+}
+
+type specGenConfig struct {
+	Name   string            `hcl:"name"`
+	Zone   *string           `hcl:"zone"`
+	Tags   map[string]string `hcl:"tags,optional"`
+	Limits struct {
+		CPU    float64 `cty:"cpu"`
+		Memory int     `cty:"memory"`
+	} `hcl:"limits,optional"`
+	Log     *specGenLog     `hcl:"log,block"`
+	Servers []specGenServer `hcl:"server,block"`
+	Skipped string
+}
+
+type specGenLoop struct {
+	Child *specGenLoop `hcl:"child,block"`
+}
+
+func TestGenerateSpecRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "specgen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	spec, err := GenerateSpec(&specGenConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	specFile := filepath.Join(dir, "config.hcldec")
+	if err := ioutil.WriteFile(specFile, spec, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, diags := LoadSpecFile(specFile); diags.HasErrors() {
+		t.Fatalf("%s\n%s", diags, spec)
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		want  map[string]interface{}
+		err   string
+	}{
+		{
+			name: "full",
+			input: `name = "app"
+zone = "eu"
+tags = { team = "core" }
+limits = { cpu = 0.5, memory = 512 }
+log {
+  level = "debug"
+}
+server "web" {
+  port  = 80
+  hosts = ["a", "b"]
+}
+server "db" {
+  port = 5432
+}
+`,
+			want: map[string]interface{}{
+				"name":   "app",
+				"zone":   "eu",
+				"tags":   map[string]interface{}{"team": "core"},
+				"limits": map[string]interface{}{"cpu": 0.5, "memory": 512.0},
+				"log":    map[string]interface{}{"level": "debug"},
+				"server": map[string]interface{}{
+					"web": map[string]interface{}{"port": 80.0, "hosts": []interface{}{"a", "b"}},
+					"db":  map[string]interface{}{"port": 5432.0, "hosts": nil},
+				},
+			},
+		},
+		{
+			name:  "optional left out",
+			input: "name = \"app\"\n",
+			want: map[string]interface{}{
+				"name":   "app",
+				"zone":   nil,
+				"tags":   nil,
+				"limits": nil,
+				"log":    nil,
+				"server": map[string]interface{}{},
+			},
+		},
+		{
+			name:  "required attribute missing",
+			input: "zone = \"eu\"\n",
+			err:   `The argument "name" is required`,
+		},
+		{
+			name:  "wrong type",
+			input: "name = \"app\"\ntags = [\"x\"]\n",
+			err:   "map of string required",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := filepath.Join(dir, "input.hcl")
+			if err := ioutil.WriteFile(input, []byte(tt.input), 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := DecodeWithSpecMap(specFile, []string{input}, nil)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateSpecErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		v    interface{}
+		err  string
+	}{
+		{"not a struct", 1, "expected a struct"},
+		{"nested block", specGenLoop{}, "within itself"},
+		{"map key", struct {
+			M map[int]string `hcl:"m"`
+		}{}, "map keys must be strings"},
+		{"no type", struct {
+			C chan int `hcl:"c"`
+		}{}, "has no HCL type"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := GenerateSpec(tt.v); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
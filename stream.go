@@ -0,0 +1,167 @@
+package goreflect
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// DocumentDecoder reads a stream of documents one at a time. YAML streams
+// are split on `---` markers and JSON streams may be JSON Lines, any
+// whitespace separated sequence of objects, or a single top level array of
+// objects. Other types yield their whole input as one document.
+type DocumentDecoder struct {
+	t    TYPE
+	next func() (interface{}, error)
+	n    int
+}
+
+// NewDocumentDecoder returns a decoder reading documents of type t from r.
+// YAML and JSON input is read incrementally, so memory use is bounded by
+// the size of the largest document.
+func NewDocumentDecoder(r io.Reader, t TYPE) *DocumentDecoder {
+	d := &DocumentDecoder{t: t}
+	switch t {
+	case YAML:
+		dec := yaml.NewDecoder(r)
+		d.next = func() (interface{}, error) {
+			var v interface{}
+			err := dec.Decode(&v)
+			return v, err
+		}
+	case JSON:
+		d.next = jsonDocuments(bufio.NewReader(r))
+	default:
+		done := false
+		d.next = func() (interface{}, error) {
+			if done {
+				return nil, io.EOF
+			}
+			done = true
+			c := make(map[string]interface{})
+			if err := MarshalReader(r, t, c); err != nil {
+				return nil, err
+			}
+			return c, nil
+		}
+	}
+	return d
+}
+
+// Next returns the next document, or io.EOF once the stream is exhausted.
+// Empty YAML documents are skipped. Documents decode to the same values as
+// MarshalReader gives for each of them alone, with lower-cased keys and
+// JSON numbers as float64.
+func (d *DocumentDecoder) Next() (map[string]interface{}, error) {
+	for {
+		v, err := d.next()
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		d.n++
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s document %d", d.t, d.n)
+		}
+		if v == nil {
+			continue
+		}
+		c, ok := stringKeys(v).(map[string]interface{})
+		if !ok {
+			return nil, errors.Errorf("%s document %d: expected a mapping, got %T", d.t, d.n, v)
+		}
+		InsensitivizeMap(c)
+		return c, nil
+	}
+}
+
+// jsonDocuments returns an iterator over the values of a JSON stream,
+// descending into a top level array.
+func jsonDocuments(r *bufio.Reader) func() (interface{}, error) {
+	dec := json.NewDecoder(r)
+	started, array := false, false
+	return func() (interface{}, error) {
+		if !started {
+			started = true
+			array = firstNonSpace(r) == '['
+			if array {
+				if _, err := dec.Token(); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if array && !dec.More() {
+			if _, err := dec.Token(); err != nil {
+				return nil, err
+			}
+			array = false
+			return nil, io.EOF
+		}
+		var v interface{}
+		if err := dec.Decode(&v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+}
+
+func firstNonSpace(r *bufio.Reader) byte {
+	for i := 1; ; i++ {
+		b, err := r.Peek(i)
+		if err != nil || len(b) < i {
+			return 0
+		}
+		switch c := b[i-1]; c {
+		case ' ', '\t', '\r', '\n':
+		default:
+			return c
+		}
+	}
+}
+
+// DocumentEncoder writes a stream of documents. YAML documents are
+// separated by `---` markers and JSON documents are written as JSON Lines.
+// Other types accept a single document.
+type DocumentEncoder struct {
+	w    io.Writer
+	t    TYPE
+	yaml *yaml.Encoder
+	json *json.Encoder
+	n    int
+}
+
+// NewDocumentEncoder returns an encoder writing documents of type t to w.
+func NewDocumentEncoder(w io.Writer, t TYPE) *DocumentEncoder {
+	e := &DocumentEncoder{w: w, t: t}
+	switch t {
+	case YAML:
+		e.yaml = yaml.NewEncoder(w)
+	case JSON:
+		e.json = json.NewEncoder(w)
+	}
+	return e
+}
+
+// Encode writes c as the next document.
+func (e *DocumentEncoder) Encode(c map[string]interface{}) error {
+	e.n++
+	switch {
+	case e.yaml != nil:
+		return errors.WithStack(e.yaml.Encode(c))
+	case e.json != nil:
+		return errors.WithStack(e.json.Encode(c))
+	case e.n > 1:
+		return errors.Errorf("%s does not support multiple documents in one stream", e.t)
+	}
+	return MarshalWriter(e.w, c, e.t)
+}
+
+// Close flushes any buffered output. It does not close the underlying writer.
+func (e *DocumentEncoder) Close() error {
+	if e.yaml != nil {
+		return errors.WithStack(e.yaml.Close())
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package goreflect
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDocumentDecoder(t *testing.T) {
+	tests := []struct {
+		name string
+		typ  TYPE
+		in   string
+		want []string // each document alone, decoded with MarshalReader
+	}{
+		{
+			name: "yaml stream",
+			typ:  YAML,
+			in:   "---\nName: a\nport: 80\n---\n# empty\n---\nname: b\nlist: [1, 2.5]\n...\n",
+			want: []string{"Name: a\nport: 80\n", "name: b\nlist: [1, 2.5]\n"},
+		},
+		{
+			name: "concatenated json",
+			typ:  JSON,
+			in:   `{"a": 1, "B": {"c": 2.5}} {"a": 2}` + "\n" + `{"a": 3}`,
+			want: []string{`{"a": 1, "B": {"c": 2.5}}`, `{"a": 2}`, `{"a": 3}`},
+		},
+		{
+			name: "json array",
+			typ:  JSON,
+			in:   ` [{"a": 1}, {"a": 9007199254740993}] `,
+			want: []string{`{"a": 1}`, `{"a": 9007199254740993}`},
+		},
+		{
+			name: "empty",
+			typ:  JSON,
+			in:   "",
+		},
+		{
+			name: "toml is one document",
+			typ:  TOML,
+			in:   "a = 1\n[b]\nc = \"x\"\n",
+			want: []string{"a = 1\n[b]\nc = \"x\"\n"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dec := NewDocumentDecoder(strings.NewReader(tt.in), tt.typ)
+			for i, doc := range tt.want {
+				got, err := dec.Next()
+				if err != nil {
+					t.Fatalf("document %d: %v", i, err)
+				}
+				want := make(map[string]interface{})
+				if err := MarshalReader(strings.NewReader(doc), tt.typ, want); err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("document %d: got %#v, want %#v", i, got, want)
+				}
+			}
+			for i := 0; i < 2; i++ {
+				if _, err := dec.Next(); err != io.EOF {
+					t.Fatalf("got %v, want io.EOF", err)
+				}
+			}
+		})
+	}
+}
+
+func TestDocumentDecoderErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		typ  TYPE
+		in   string
+		err  string
+	}{
+		{"yaml scalar", YAML, "a: 1\n---\n- x\n", "YAML document 2: expected a mapping"},
+		{"bad json", JSON, `{"a": 1} {"a":`, "JSON document 2"},
+		{"json scalar", JSON, `{"a": 1} 2`, "JSON document 2: expected a mapping"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dec := NewDocumentDecoder(strings.NewReader(tt.in), tt.typ)
+			if _, err := dec.Next(); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := dec.Next(); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestDocumentEncoder(t *testing.T) {
+	docs := []map[string]interface{}{{"a": 1}, {"b": "x"}}
+	tests := []struct {
+		typ  TYPE
+		want string
+		err  bool
+	}{
+		{YAML, "a: 1\n---\nb: x\n", false},
+		{JSON, "{\"a\":1}\n{\"b\":\"x\"}\n", false},
+		{TOML, "a = 1\n", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.typ.String(), func(t *testing.T) {
+			var buf bytes.Buffer
+			enc := NewDocumentEncoder(&buf, tt.typ)
+			var err error
+			for _, doc := range docs {
+				if err = enc.Encode(doc); err != nil {
+					break
+				}
+			}
+			if (err != nil) != tt.err {
+				t.Fatalf("got error %v, want error %v", err, tt.err)
+			}
+			if err := enc.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("got %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"unicode"
 )
 
 func Base64encode(v string) string {
@@ -309,3 +310,61 @@ func StringInSlice(a string, list []string) bool {
 	}
 	return false
 }
+
+// SplitWords splits an identifier into words at case changes, digits
+// following letters stay with their word, and at '_', '-', '.' and spaces:
+// "HTTPServerURL2" becomes ["HTTP", "Server", "URL2"].
+func SplitWords(s string) []string {
+	var words []string
+	var cur []rune
+	runes := []rune(s)
+	for i, r := range runes {
+		if r == '_' || r == '-' || r == '.' || unicode.IsSpace(r) {
+			if len(cur) > 0 {
+				words = append(words, string(cur))
+				cur = nil
+			}
+			continue
+		}
+		if len(cur) > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				words = append(words, string(cur))
+				cur = nil
+			}
+		}
+		cur = append(cur, r)
+	}
+	if len(cur) > 0 {
+		words = append(words, string(cur))
+	}
+	return words
+}
+
+func SnakeCase(s string) string {
+	return strings.ToLower(strings.Join(SplitWords(s), "_"))
+}
+
+func KebabCase(s string) string {
+	return strings.ToLower(strings.Join(SplitWords(s), "-"))
+}
+
+func CamelCase(s string) string {
+	words := SplitWords(s)
+	for i, w := range words {
+		r := []rune(w)
+		words[i] = strings.ToUpper(string(r[:1])) + strings.ToLower(string(r[1:]))
+	}
+	return strings.Join(words, "")
+}
+
+func LowerCamelCase(s string) string {
+	c := CamelCase(s)
+	if c == "" {
+		return c
+	}
+	r := []rune(c)
+	r[0] = unicode.ToLower(r[0])
+	return string(r)
+}
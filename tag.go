@@ -3,12 +3,31 @@ package goreflect
 import (
 	"errors"
 	"fmt"
-	"github.com/spf13/viper"
+	"reflect"
 	"strconv"
+	"sync"
 )
 
 func scanMultiTag(field string) (map[string][]string, error) {
+	pairs, err := scanTagPairs(field)
+	if err != nil {
+		return nil, err
+	}
 	mtag := make(map[string][]string)
+	for _, p := range pairs {
+		mtag[p.key] = append(mtag[p.key], p.value)
+	}
+	return mtag, nil
+}
+
+// tagPair is one key:"value" pair of a struct tag.
+type tagPair struct {
+	key, value string
+}
+
+// scanTagPairs splits a struct tag into its pairs, in order.
+func scanTagPairs(field string) ([]tagPair, error) {
+	var pairs []tagPair
 	// This is mostly copied from reflect.StructTag.Get
 	for field != "" {
 		i := 0
@@ -81,51 +100,144 @@ func scanMultiTag(field string) (map[string][]string, error) {
 
 		field = field[i+1:]
 
-		mtag[name] = append(mtag[name], val)
+		pairs = append(pairs, tagPair{name, val})
 	}
 
-	return mtag, nil
+	return pairs, nil
 }
 
-func ParseMultiTag(field string) error {
-	vals, err := scanMultiTag(field)
-	viper.Set(field, vals)
+// TagCache keeps struct tags parsed by scanMultiTag, either per struct
+// field or per raw tag string. It is safe for concurrent use; the maps it
+// returns are copies the caller may modify.
+type TagCache struct {
+	fields sync.Map   // tagField -> *parsedTag
+	tags   sync.Map   // string -> *parsedTag
+	mu     sync.Mutex // serializes Set and SetField
+}
 
-	return err
+type tagField struct {
+	t     reflect.Type
+	index int
 }
 
-func Cached(field string) map[string][]string {
-	if viper.GetStringMapStringSlice(field) == nil {
-		cache, _ := scanMultiTag(field)
-		if cache == nil {
-			cache = make(map[string][]string)
-		}
-		viper.Set(field, cache)
+type parsedTag struct {
+	vals map[string][]string
+	err  error
+}
+
+// NewTagCache returns an empty TagCache.
+func NewTagCache() *TagCache {
+	return &TagCache{}
+}
+
+var defaultTagCache = NewTagCache()
+
+// Field returns the parsed tag of field i of the struct type t.
+func (c *TagCache) Field(t reflect.Type, i int) (map[string][]string, error) {
+	p := c.field(t, i)
+	return copyTag(p.vals), p.err
+}
+
+// field is Field without the copy, for callers that only read.
+func (c *TagCache) field(t reflect.Type, i int) *parsedTag {
+	key := tagField{t, i}
+	if p, ok := c.fields.Load(key); ok {
+		return p.(*parsedTag)
 	}
-	return viper.GetStringMapStringSlice(field)
+	vals, err := scanMultiTag(string(t.Field(i).Tag))
+	p, _ := c.fields.LoadOrStore(key, &parsedTag{vals, err})
+	return p.(*parsedTag)
 }
 
-func Get(field string) string {
-	c := viper.GetStringMapStringSlice(field)
+// Lookup returns the last value of key in the tag of field i of t.
+func (c *TagCache) Lookup(t reflect.Type, i int, key string) (string, bool) {
+	if v := c.field(t, i).vals[key]; len(v) > 0 {
+		return v[len(v)-1], true
+	}
+	return "", false
+}
 
-	if field, ok := c[field]; ok {
-		return field[len(field)-1]
+// SetField overrides key in the tag of field i of t.
+func (c *TagCache) SetField(t reflect.Type, i int, key string, values ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	vals, err := c.Field(t, i)
+	if vals == nil {
+		vals = make(map[string][]string)
 	}
+	vals[key] = values
+	c.fields.Store(tagField{t, i}, &parsedTag{vals, err})
+}
 
+// Parse parses a raw tag string and caches the result, even on error.
+func (c *TagCache) Parse(tag string) (map[string][]string, error) {
+	vals, err := scanMultiTag(tag)
+	if vals == nil {
+		vals = make(map[string][]string)
+	}
+	c.tags.Store(tag, &parsedTag{vals, err})
+	return copyTag(vals), err
+}
+
+// Tag returns the parsed form of a raw tag string, parsing it on first use.
+// A malformed tag yields an empty map.
+func (c *TagCache) Tag(tag string) map[string][]string {
+	if p, ok := c.tags.Load(tag); ok {
+		return copyTag(p.(*parsedTag).vals)
+	}
+	vals, _ := c.Parse(tag)
+	return vals
+}
+
+// Get returns the values of key in a raw tag string.
+func (c *TagCache) Get(tag, key string) []string {
+	return c.Tag(tag)[key]
+}
+
+// Set overrides key in the cached form of a raw tag string.
+func (c *TagCache) Set(tag, key string, values ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	vals := c.Tag(tag)
+	vals[key] = values
+	c.tags.Store(tag, &parsedTag{vals: vals})
+}
+
+func copyTag(vals map[string][]string) map[string][]string {
+	if vals == nil {
+		return nil
+	}
+	out := make(map[string][]string, len(vals))
+	for k, v := range vals {
+		out[k] = append([]string(nil), v...)
+	}
+	return out
+}
+
+func ParseMultiTag(field string) error {
+	_, err := defaultTagCache.Parse(field)
+	return err
+}
+
+func Cached(field string) map[string][]string {
+	return defaultTagCache.Tag(field)
+}
+
+func Get(field string) string {
+	if vals := defaultTagCache.Get(field, field); len(vals) > 0 {
+		return vals[len(vals)-1]
+	}
 	return ""
 }
 
 func GetMany(field string) []string {
-	c := viper.GetStringMapStringSlice(field)
-	return c[field]
+	return defaultTagCache.Get(field, field)
 }
 
 func Set(field string, value string) {
-	c := viper.GetStringMapStringSlice(field)
-	c[field] = []string{value}
+	defaultTagCache.Set(field, field, value)
 }
 
 func SetMany(field string, value []string) {
-	c := viper.GetStringMapStringSlice(field)
-	c[field] = value
+	defaultTagCache.Set(field, field, value...)
 }
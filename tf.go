@@ -0,0 +1,336 @@
+package goreflect
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hclparse"
+	"github.com/pkg/errors"
+	"github.com/zclconf/go-cty/cty"
+)
+
+var (
+	// ErrTFSpecRequired is returned when HCL input is decoded as TF without
+	// a spec file.
+	ErrTFSpecRequired = errors.New(`TF: TFOptions.SpecFile is required to decode HCL input, see: "https://github.com/hashicorp/hcl2/blob/master/cmd/hcldec/spec-format.md"`)
+	// ErrTFStateRequired is returned when TF output is encoded without a
+	// state path.
+	ErrTFStateRequired = errors.New("TF: TFOptions.StatePath is required to encode terraform outputs")
+)
+
+// TFOptions configures the TF codec.
+type TFOptions struct {
+	// SpecFile is the .hcldec spec applied to HCL input.
+	SpecFile string
+	// StatePath is a terraform.tfstate file, or a directory containing one.
+	StatePath string
+	// Vars are added to the variables declared by the spec file.
+	Vars map[string]cty.Value
+}
+
+// TFCodec handles Terraform data without invoking the terraform binary.
+//
+// Decode accepts either a Terraform state document, which is parsed
+// natively, or HCL which is decoded with Options.SpecFile. Encode ignores
+// its input map and writes the outputs of the state at Options.StatePath in
+// the format of `terraform output -json`.
+//
+// The codec registered for TF has no options, so it only decodes state.
+// Pass options with DecodeOptions.TF and WriteOptions.TF, or use a TFCodec
+// directly.
+type TFCodec struct {
+	Options TFOptions
+}
+
+func init() {
+	RegisterCodec(TF, "TF", &TFCodec{})
+}
+
+func (t *TFCodec) Decode(r io.Reader, c map[string]interface{}) error {
+	b, err := readAll(r)
+	if err != nil {
+		return err
+	}
+
+	if isTFState(b) {
+		state, err := ReadTFState(bytes.NewReader(b))
+		if err != nil {
+			return err
+		}
+		for k, v := range state.Map() {
+			c[k] = v
+		}
+		return nil
+	}
+
+	if t.Options.SpecFile == "" {
+		return ErrTFSpecRequired
+	}
+	return t.decodeHCL(b, c)
+}
+
+func (t *TFCodec) decodeHCL(b []byte, c map[string]interface{}) error {
+	spec, diags := LoadSpecFile(t.Options.SpecFile)
+	if diags.HasErrors() {
+		return errors.WithStack(diags)
+	}
+
+	var file *hcl.File
+	if trimmed := bytes.TrimSpace(b); len(trimmed) > 0 && trimmed[0] == '{' {
+		file, diags = hclparse.NewParser().ParseJSON(b, "<input>")
+	} else {
+		file, diags = hclparse.NewParser().ParseHCL(b, "<input>")
+	}
+	if diags.HasErrors() {
+		return errors.WithStack(diags)
+	}
+
+	val, diags := decodeSpec(spec, []*hcl.File{file}, t.Options.Vars)
+	if diags.HasErrors() {
+		return errors.WithStack(diags)
+	}
+	if !val.Type().IsObjectType() && !val.Type().IsMapType() {
+		return errors.Errorf("TF: spec %s produced %s, expected an object", t.Options.SpecFile, val.Type().FriendlyName())
+	}
+
+	m, err := CtyToMap(val)
+	if err != nil {
+		return err
+	}
+	for k, v := range m {
+		c[k] = v
+	}
+	return nil
+}
+
+func (t *TFCodec) Encode(w io.Writer, c map[string]interface{}) error {
+	if t.Options.StatePath == "" {
+		return ErrTFStateRequired
+	}
+	state, err := LoadTFState(t.Options.StatePath)
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(state.Outputs, "", "  ")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	_, err = fmt.Fprintf(w, "%s\n", b)
+	return errors.WithStack(err)
+}
+
+func (t *TFCodec) Extensions() []string { return []string{".tfstate"} }
+func (t *TFCodec) MimeType() string     { return "application/json" }
+
+// TFState is the part of a Terraform state file goreflect understands. Both
+// the version 3 format of Terraform 0.11 and the version 4 format of later
+// releases are read into it.
+type TFState struct {
+	Version          int                 `json:"version"`
+	TerraformVersion string              `json:"terraform_version"`
+	Outputs          map[string]TFOutput `json:"outputs"`
+	Resources        []TFResource        `json:"resources"`
+}
+
+// TFOutput is a root module output value.
+type TFOutput struct {
+	Sensitive bool        `json:"sensitive"`
+	Type      interface{} `json:"type,omitempty"`
+	Value     interface{} `json:"value"`
+}
+
+// TFResource is a managed resource or data source.
+type TFResource struct {
+	Module    string       `json:"module,omitempty"`
+	Mode      string       `json:"mode"`
+	Type      string       `json:"type"`
+	Name      string       `json:"name"`
+	Provider  string       `json:"provider"`
+	Instances []TFInstance `json:"instances"`
+}
+
+// TFInstance is one instance of a resource, as created by count or for_each.
+type TFInstance struct {
+	IndexKey   interface{}            `json:"index_key,omitempty"`
+	Attributes map[string]interface{} `json:"attributes"`
+}
+
+// Address returns the resource address, e.g. "module.net.aws_vpc.main".
+func (r TFResource) Address() string {
+	addr := r.Type + "." + r.Name
+	if r.Mode == "data" {
+		addr = "data." + addr
+	}
+	if r.Module != "" {
+		addr = r.Module + "." + addr
+	}
+	return addr
+}
+
+// Map flattens the state into outputs, keyed by name, and resource
+// instance attributes, keyed by instance address.
+func (s *TFState) Map() map[string]interface{} {
+	outputs := make(map[string]interface{}, len(s.Outputs))
+	for name, o := range s.Outputs {
+		outputs[name] = o.Value
+	}
+	resources := make(map[string]interface{})
+	for _, r := range s.Resources {
+		addr := r.Address()
+		for _, inst := range r.Instances {
+			key := addr
+			switch k := inst.IndexKey.(type) {
+			case nil:
+			case string:
+				key = fmt.Sprintf("%s[%q]", addr, k)
+			default:
+				key = fmt.Sprintf("%s[%v]", addr, k)
+			}
+			resources[key] = inst.Attributes
+		}
+	}
+	return map[string]interface{}{
+		"version":           s.Version,
+		"terraform_version": s.TerraformVersion,
+		"outputs":           outputs,
+		"resources":         resources,
+	}
+}
+
+// LoadTFState reads a state file, or the terraform.tfstate file inside a
+// directory.
+func LoadTFState(path string) (*TFState, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if fi.IsDir() {
+		path = filepath.Join(path, "terraform.tfstate")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer f.Close()
+	state, err := ReadTFState(f)
+	return state, errors.Wrapf(err, "reading %s", path)
+}
+
+// ReadTFState parses a Terraform state document.
+func ReadTFState(r io.Reader) (*TFState, error) {
+	b, err := readAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var head struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(b, &head); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if head.Version < 4 {
+		return readTFStateV3(b)
+	}
+
+	state := &TFState{}
+	if err := json.Unmarshal(b, state); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if state.Outputs == nil {
+		state.Outputs = make(map[string]TFOutput)
+	}
+	return state, nil
+}
+
+func readTFStateV3(b []byte) (*TFState, error) {
+	var v3 struct {
+		Version          int    `json:"version"`
+		TerraformVersion string `json:"terraform_version"`
+		Modules          []struct {
+			Path      []string            `json:"path"`
+			Outputs   map[string]TFOutput `json:"outputs"`
+			Resources map[string]struct {
+				Type     string `json:"type"`
+				Provider string `json:"provider"`
+				Primary  struct {
+					ID         string                 `json:"id"`
+					Attributes map[string]interface{} `json:"attributes"`
+				} `json:"primary"`
+			} `json:"resources"`
+		} `json:"modules"`
+	}
+	if err := json.Unmarshal(b, &v3); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	state := &TFState{
+		Version:          v3.Version,
+		TerraformVersion: v3.TerraformVersion,
+		Outputs:          make(map[string]TFOutput),
+	}
+	for _, m := range v3.Modules {
+		// the root module has path ["root"], older states may omit it
+		var module string
+		if len(m.Path) > 1 {
+			for _, p := range m.Path[1:] {
+				module += ".module." + p
+			}
+			module = strings.TrimPrefix(module, ".")
+		}
+		if module == "" {
+			for name, o := range m.Outputs {
+				state.Outputs[name] = o
+			}
+		}
+
+		keys := make([]string, 0, len(m.Resources))
+		for k := range m.Resources {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			res := m.Resources[k]
+			r := TFResource{Module: module, Mode: "managed", Type: res.Type, Provider: res.Provider}
+			parts := strings.Split(k, ".")
+			if parts[0] == "data" {
+				r.Mode, parts = "data", parts[1:]
+			}
+			inst := TFInstance{Attributes: res.Primary.Attributes}
+			if len(parts) > 2 {
+				inst.IndexKey = ToInt(parts[2])
+			}
+			if len(parts) > 1 {
+				r.Name = parts[1]
+			}
+			if inst.Attributes == nil {
+				inst.Attributes = make(map[string]interface{})
+			}
+			if _, ok := inst.Attributes["id"]; !ok && res.Primary.ID != "" {
+				inst.Attributes["id"] = res.Primary.ID
+			}
+			r.Instances = []TFInstance{inst}
+			state.Resources = append(state.Resources, r)
+		}
+	}
+	return state, nil
+}
+
+// isTFState reports whether b looks like a Terraform state document.
+func isTFState(b []byte) bool {
+	var head map[string]json.RawMessage
+	if err := json.Unmarshal(b, &head); err != nil {
+		return false
+	}
+	_, version := head["version"]
+	_, lineage := head["lineage"]
+	_, modules := head["modules"]
+	_, resources := head["resources"]
+	return version && (lineage || modules || resources)
+}
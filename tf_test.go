@@ -0,0 +1,114 @@
+package goreflect
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestReadTFState(t *testing.T) {
+	tests := []struct {
+		name      string
+		in        string
+		outputs   map[string]interface{}
+		addresses []string
+	}{
+		{
+			name:    "v3 without path",
+			in:      `{"version":3,"modules":[{"outputs":{}}]}`,
+			outputs: map[string]interface{}{},
+		},
+		{
+			name: "v3 modules",
+			in: `{"version":3,"modules":[
+				{"path":["root"],"outputs":{"ip":{"value":"10.0.0.1","type":"string"}},
+				 "resources":{"aws_instance.web.1":{"type":"aws_instance","primary":{"id":"i-1","attributes":{"id":"i-1"}}}}},
+				{"path":["root","net"],"outputs":{"hidden":{"value":"x"}},
+				 "resources":{"data.aws_vpc.main":{"type":"aws_vpc","primary":{"id":"vpc-1","attributes":{"id":"vpc-1"}}}}}
+			]}`,
+			outputs:   map[string]interface{}{"ip": "10.0.0.1"},
+			addresses: []string{"aws_instance.web[1]", "module.net.data.aws_vpc.main"},
+		},
+		{
+			name: "v4",
+			in: `{"version":4,"terraform_version":"0.12.0",
+				"outputs":{"ip":{"value":"10.0.0.1","type":"string"}},
+				"resources":[{"mode":"managed","type":"aws_instance","name":"web","instances":[
+					{"index_key":"a","attributes":{"id":"i-1"}}]}]}`,
+			outputs:   map[string]interface{}{"ip": "10.0.0.1"},
+			addresses: []string{`aws_instance.web["a"]`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state, err := ReadTFState(strings.NewReader(tt.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := state.Map()
+			if !reflect.DeepEqual(m["outputs"], tt.outputs) {
+				t.Errorf("outputs: got %v, want %v", m["outputs"], tt.outputs)
+			}
+			var addrs []string
+			for k := range m["resources"].(map[string]interface{}) {
+				addrs = append(addrs, k)
+			}
+			sort.Strings(addrs)
+			if !reflect.DeepEqual(addrs, tt.addresses) {
+				t.Errorf("resources: got %v, want %v", addrs, tt.addresses)
+			}
+		})
+	}
+}
+
+func TestTFOptions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	spec := filepath.Join(dir, "spec.hcldec")
+	state := filepath.Join(dir, "terraform.tfstate")
+	files := map[string]string{
+		spec:  "object {\n  attr \"name\" {\n    type = string\n  }\n}\n",
+		state: `{"version":4,"outputs":{"ip":{"value":"10.0.0.1","type":"string"}}}`,
+	}
+	for name, src := range files {
+		if err := ioutil.WriteFile(name, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	const in = `name = "web"`
+	if err := MarshalReader(strings.NewReader(in), TF, map[string]interface{}{}); err != ErrTFSpecRequired {
+		t.Errorf("got %v, want ErrTFSpecRequired", err)
+	}
+	c := make(map[string]interface{})
+	if err := MarshalReaderWith(strings.NewReader(in), TF, c, DecodeOptions{TF: &TFOptions{SpecFile: spec}}); err != nil {
+		t.Fatal(err)
+	}
+	if c["name"] != "web" {
+		t.Errorf("got %v", c)
+	}
+
+	if err := MarshalWriter(ioutil.Discard, nil, TF); err != ErrTFStateRequired {
+		t.Errorf("got %v, want ErrTFStateRequired", err)
+	}
+	var buf bytes.Buffer
+	if err := MarshalWriterWith(&buf, nil, TF, WriteOptions{TF: &TFOptions{StatePath: dir}}); err != nil {
+		t.Fatal(err)
+	}
+	var out map[string]TFOutput
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out["ip"].Value != "10.0.0.1" {
+		t.Errorf("got %s", buf.Bytes())
+	}
+}
@@ -0,0 +1,76 @@
+package goreflect
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"strconv"
+
+	"github.com/pelletier/go-toml"
+	"github.com/pkg/errors"
+)
+
+type tomlCodec struct{}
+
+func (tomlCodec) Decode(r io.Reader, c map[string]interface{}) error {
+	tree, err := toml.LoadReader(r)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	for k, v := range tree.ToMap() {
+		c[k] = v
+	}
+	return nil
+}
+
+func (tomlCodec) Encode(w io.Writer, c map[string]interface{}) error {
+	tree, err := toml.TreeFromMap(quoteTOMLKeys(stringKeys(c)).(map[string]interface{}))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	_, err = tree.WriteTo(w)
+	return errors.WithStack(err)
+}
+
+var tomlBareKeyRe = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// quoteTOMLKeys quotes every key that is not a valid bare key, since the
+// tree writer emits keys verbatim.
+func quoteTOMLKeys(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			if !tomlBareKeyRe.MatchString(k) {
+				k = strconv.Quote(k)
+			}
+			m[k] = quoteTOMLKeys(val)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, val := range v {
+			s[i] = quoteTOMLKeys(val)
+		}
+		return s
+	}
+	return v
+}
+
+func (tomlCodec) Extensions() []string { return []string{".toml"} }
+func (tomlCodec) MimeType() string     { return "application/toml" }
+
+var tomlTableRe = regexp.MustCompile(`^\[\[?\s*[\w"'.\- ]+\s*\]\]?$`)
+
+func (tomlCodec) Sniff(head []byte) float64 {
+	line, _ := significant(head)
+	switch {
+	case line == nil:
+		return 0
+	case tomlTableRe.Match(line) && !bytes.ContainsAny(line, ","):
+		return 0.85
+	case hclAttrRe.Match(line):
+		return 0.6
+	}
+	return 0
+}
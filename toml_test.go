@@ -0,0 +1,80 @@
+package goreflect
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTOMLCodec(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want map[string]interface{}
+	}{
+		{
+			name: "scalars",
+			in:   "name = \"web\"\nport = 80\nratio = 0.5\non = true\n",
+			want: map[string]interface{}{"name": "web", "port": int64(80), "ratio": 0.5, "on": true},
+		},
+		{
+			name: "tables",
+			in:   "[server]\nhost = \"a\"\n[server.tls]\nenabled = true\n",
+			want: map[string]interface{}{
+				"server": map[string]interface{}{
+					"host": "a",
+					"tls":  map[string]interface{}{"enabled": true},
+				},
+			},
+		},
+		{
+			name: "arrays of tables",
+			in:   "tags = [\"a\", \"b\"]\n[[rule]]\nid = 1\n[[rule]]\nid = 2\n",
+			want: map[string]interface{}{
+				"tags": []interface{}{"a", "b"},
+				"rule": []interface{}{
+					map[string]interface{}{"id": int64(1)},
+					map[string]interface{}{"id": int64(2)},
+				},
+			},
+		},
+		{
+			name: "quoted keys",
+			in:   "\"a b\" = 1\n[\"x.y\"]\nz = 2\n",
+			want: map[string]interface{}{
+				"a b": int64(1),
+				"x.y": map[string]interface{}{"z": int64(2)},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := make(map[string]interface{})
+			if err := (tomlCodec{}).Decode(strings.NewReader(tt.in), c); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(c, tt.want) {
+				t.Fatalf("got %#v, want %#v", c, tt.want)
+			}
+
+			var buf bytes.Buffer
+			if err := (tomlCodec{}).Encode(&buf, c); err != nil {
+				t.Fatal(err)
+			}
+			again := make(map[string]interface{})
+			if err := (tomlCodec{}).Decode(&buf, again); err != nil {
+				t.Fatalf("%v in:\n%s", err, buf.Bytes())
+			}
+			if !reflect.DeepEqual(again, tt.want) {
+				t.Errorf("round trip got %#v, want %#v", again, tt.want)
+			}
+		})
+	}
+}
+
+func TestTOMLCodecError(t *testing.T) {
+	if err := (tomlCodec{}).Decode(strings.NewReader("a = "), map[string]interface{}{}); err == nil {
+		t.Error("expected an error")
+	}
+}
@@ -1,7 +1,6 @@
 package goreflect
 
 import (
-	"bufio"
 	"encoding/json"
 	"fmt"
 	"github.com/pkg/errors"
@@ -73,10 +72,3 @@ func pathExists(path string) bool {
 	}
 	return false
 }
-
-func prompt(prompt string) string {
-	reader := bufio.NewReader(os.Stdin)
-	fmt.Print(prompt)
-	text, _ := reader.ReadString('\n')
-	return text
-}
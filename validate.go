@@ -0,0 +1,271 @@
+package goreflect
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ValidationError collects every rule Validate found violated.
+type ValidationError struct {
+	Errors []*FieldError
+}
+
+func (e *ValidationError) Error() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%d error(s) validating:", len(e.Errors))
+	for _, fe := range e.Errors {
+		buf.WriteString("\n* ")
+		buf.WriteString(fe.Error())
+	}
+	return buf.String()
+}
+
+var validateRegexps sync.Map // string -> *regexp.Regexp
+
+// Validate checks the struct v, or the struct v points to, against the
+// rules in its `validate` tags:
+//
+//	required     the field is not empty, as reported by IsEmpty
+//	min=N        numbers are at least N, strings, slices and maps have at
+//	             least N elements; durations take N as a duration
+//	max=N        the same, at most N
+//	oneof=a b c  the field, as a string, is one of the listed values
+//	regexp=RE    the field, as a string, matches RE; as the regexp may hold
+//	             commas it takes the rest of the tag
+//	omitempty    skip the other rules when the field is empty
+//
+// Rules other than required apply to zero values too, so min=1 rejects 0,
+// but not to nil pointers and interfaces, which hold no value. Nested
+// structs, pointers, slices and maps are walked, and every violation is
+// reported in a *ValidationError with the path of the field, e.g.
+// "Servers[0].Port".
+func Validate(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return errors.Errorf("Validate: expected a struct, got nil %T", v)
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return errors.Errorf("Validate: expected a struct, got %T", v)
+	}
+	val := &validator{seen: make(map[uintptr]bool)}
+	val.walk("", reflect.ValueOf(v))
+	if len(val.errs) > 0 {
+		return &ValidationError{Errors: val.errs}
+	}
+	return nil
+}
+
+type validator struct {
+	errs []*FieldError
+	seen map[uintptr]bool
+}
+
+func (val *validator) fail(path string, err error) {
+	val.errs = append(val.errs, &FieldError{Path: path, Err: err})
+}
+
+// walk validates the fields of the structs in v.
+func (val *validator) walk(path string, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() || val.seen[v.Pointer()] {
+			return
+		}
+		val.seen[v.Pointer()] = true
+		val.walk(path, v.Elem())
+	case reflect.Interface:
+		if !v.IsNil() {
+			val.walk(path, v.Elem())
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			val.walk(fmt.Sprintf("%s[%d]", path, i), v.Index(i))
+		}
+	case reflect.Map:
+		keys := v.MapKeys()
+		names := make(map[string]reflect.Value, len(keys))
+		sorted := make([]string, 0, len(keys))
+		for _, k := range keys {
+			name := StrVal(k.Interface())
+			names[name] = k
+			sorted = append(sorted, name)
+		}
+		sort.Strings(sorted)
+		for _, name := range sorted {
+			val.walk(fmt.Sprintf("%s[%s]", path, name), v.MapIndex(names[name]))
+		}
+	case reflect.Struct:
+		if v.Type() == timeType {
+			return
+		}
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" && !f.Anonymous {
+				continue
+			}
+			fpath := joinPath(path, f.Name)
+			p := defaultTagCache.field(t, i)
+			if p.err != nil {
+				val.fail(fpath, p.err)
+				continue
+			}
+			fv := v.Field(i)
+			if f.Anonymous {
+				// promoted fields keep the path of their parent
+				fpath = path
+			}
+			if rules := p.vals["validate"]; len(rules) > 0 && fv.CanInterface() {
+				val.check(fpath, fv, rules[len(rules)-1])
+			}
+			if fv.CanInterface() || f.Anonymous {
+				val.walk(fpath, fv)
+			}
+		}
+	}
+}
+
+// check applies the rules in tag to the field v.
+func (val *validator) check(path string, v reflect.Value, tag string) {
+	rules := splitRules(tag)
+	empty := IsEmpty(v.Interface()) || (v.Kind() == reflect.Struct && v.IsZero())
+	omitEmpty := false
+	for _, rule := range rules {
+		switch rule {
+		case "required":
+			if empty {
+				val.fail(path, errors.New("is required"))
+				return
+			}
+		case "omitempty":
+			omitEmpty = true
+		}
+	}
+	if empty && omitEmpty {
+		return
+	}
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+
+	for _, rule := range rules {
+		name, param := rule, ""
+		if i := strings.IndexByte(rule, '='); i >= 0 {
+			name, param = rule[:i], rule[i+1:]
+		}
+		var err error
+		switch name {
+		case "", "required", "omitempty":
+		case "min", "max":
+			err = checkBound(v, name, param)
+		case "oneof":
+			s := StrVal(v.Interface())
+			found := false
+			for _, choice := range strings.Fields(param) {
+				if s == choice {
+					found = true
+					break
+				}
+			}
+			if !found {
+				err = errors.Errorf("%q is not one of %s", s, strings.Join(strings.Fields(param), ", "))
+			}
+		case "regexp":
+			err = checkRegexp(v, param)
+		default:
+			err = errors.Errorf("unknown rule %q", name)
+		}
+		if err != nil {
+			val.fail(path, err)
+		}
+	}
+}
+
+// splitRules splits tag on commas, leaving a regexp rule whole.
+func splitRules(tag string) []string {
+	var rules []string
+	for tag != "" {
+		if strings.HasPrefix(tag, "regexp=") {
+			return append(rules, tag)
+		}
+		i := strings.IndexByte(tag, ',')
+		if i < 0 {
+			return append(rules, strings.TrimSpace(tag))
+		}
+		rules = append(rules, strings.TrimSpace(tag[:i]))
+		tag = strings.TrimLeft(tag[i+1:], " ")
+	}
+	return rules
+}
+
+func checkBound(v reflect.Value, name, param string) error {
+	var n, bound float64
+	var err error
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		n = float64(v.Len())
+		bound, err = ToFloat64E(param)
+	default:
+		if v.Type() == durationType {
+			var d time.Duration
+			d, err = ToDurationE(param)
+			n, bound = float64(v.Int()), float64(d)
+			break
+		}
+		if n, err = ToFloat64E(v.Interface()); err != nil {
+			return errors.Errorf("%s does not apply to %s", name, v.Type())
+		}
+		bound, err = ToFloat64E(param)
+	}
+	if err != nil {
+		return errors.Errorf("invalid %s %q", name, param)
+	}
+
+	what := "value"
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		what = "length"
+	}
+	switch {
+	case name == "min" && n < bound:
+		return errors.Errorf("%s must be at least %s", what, param)
+	case name == "max" && n > bound:
+		return errors.Errorf("%s must be at most %s", what, param)
+	}
+	return nil
+}
+
+func checkRegexp(v reflect.Value, pattern string) error {
+	var re *regexp.Regexp
+	if c, ok := validateRegexps.Load(pattern); ok {
+		re = c.(*regexp.Regexp)
+	} else {
+		var err error
+		if re, err = regexp.Compile(pattern); err != nil {
+			return errors.Wrapf(err, "invalid regexp %q", pattern)
+		}
+		validateRegexps.Store(pattern, re)
+	}
+	s, err := ToStringE(v.Interface())
+	if err != nil {
+		return errors.Errorf("regexp does not apply to %s", v.Type())
+	}
+	if !re.MatchString(s) {
+		return errors.Errorf("%q does not match %s", s, pattern)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package goreflect
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+type validateServer struct {
+	Port int `validate:"min=1,max=65535"`
+}
+
+type validateConfig struct {
+	Name     string            `validate:"required,min=2"`
+	Mode     string            `validate:"oneof=dev prod"`
+	Replicas int               `validate:"min=1"`
+	Hosts    []string          `validate:"min=1"`
+	Timeout  time.Duration     `validate:"max=1m"`
+	Email    string            `validate:"omitempty,regexp=^[^@]+@[^@]+$"`
+	Nick     *string           `validate:"min=3"`
+	Servers  []validateServer  `validate:"max=2"`
+	Labels   map[string]string `validate:"omitempty,min=2"`
+	Next     *validateConfig
+}
+
+func TestValidate(t *testing.T) {
+	short := "ab"
+	valid := func() *validateConfig {
+		return &validateConfig{
+			Name:     "web",
+			Mode:     "dev",
+			Replicas: 1,
+			Hosts:    []string{"a"},
+			Servers:  []validateServer{{Port: 80}},
+		}
+	}
+	tests := []struct {
+		name string
+		edit func(c *validateConfig)
+		want []string
+	}{
+		{
+			name: "valid",
+			edit: func(c *validateConfig) {},
+		},
+		{
+			name: "zero values",
+			edit: func(c *validateConfig) { *c = validateConfig{} },
+			want: []string{
+				"Name: is required",
+				`Mode: "" is not one of dev, prod`,
+				"Replicas: value must be at least 1",
+				"Hosts: length must be at least 1",
+			},
+		},
+		{
+			name: "omitempty skips empty values",
+			edit: func(c *validateConfig) { c.Email, c.Labels = "", nil },
+		},
+		{
+			name: "omitempty checks set values",
+			edit: func(c *validateConfig) {
+				c.Email = "nope"
+				c.Labels = map[string]string{"a": "b"}
+			},
+			want: []string{
+				`Email: "nope" does not match ^[^@]+@[^@]+$`,
+				"Labels: length must be at least 2",
+			},
+		},
+		{
+			name: "bounds",
+			edit: func(c *validateConfig) {
+				c.Name = "w"
+				c.Timeout = time.Hour
+				c.Nick = &short
+				c.Servers = []validateServer{{Port: 80}, {Port: 0}, {Port: 70000}}
+			},
+			want: []string{
+				"Name: length must be at least 2",
+				"Timeout: value must be at most 1m",
+				"Nick: length must be at least 3",
+				"Servers: length must be at most 2",
+				"Servers[1].Port: value must be at least 1",
+				"Servers[2].Port: value must be at most 65535",
+			},
+		},
+		{
+			name: "nested pointer",
+			edit: func(c *validateConfig) {
+				c.Next = valid()
+				c.Next.Mode = "test"
+			},
+			want: []string{`Next.Mode: "test" is not one of dev, prod`},
+		},
+		{
+			name: "cycle",
+			edit: func(c *validateConfig) { c.Next = c },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := valid()
+			tt.edit(c)
+			err := Validate(c)
+			var got []string
+			if err != nil {
+				verr, ok := err.(*ValidationError)
+				if !ok {
+					t.Fatalf("got %T: %v", err, err)
+				}
+				for _, fe := range verr.Errors {
+					got = append(got, fe.Error())
+				}
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestValidateNotStruct(t *testing.T) {
+	for _, v := range []interface{}{nil, 1, (*validateConfig)(nil)} {
+		if err := Validate(v); err == nil {
+			t.Errorf("Validate(%#v): expected an error", v)
+		}
+	}
+}